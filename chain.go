@@ -0,0 +1,166 @@
+package logroller
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const chainLinkPrefix = "___***___CHAIN___***___"
+
+// chainLink returns the HashChain link line that starts the file
+// opened by the rotation described by info. The first file of a
+// chain links to nothing.
+func (l *Logger) chainLink(info RotationInfo) string {
+	var prev string
+	if info.PreviousFile != "" {
+		prev = filepath.Base(info.PreviousFile)
+	}
+	line := fmt.Sprintf("%s prev=%s sha256=%s", chainLinkPrefix, prev, info.PreviousSHA256)
+	if l.SigningKey != nil && info.PreviousSHA256 != "" {
+		sig := ed25519.Sign(l.SigningKey, chainMessage(prev, info.PreviousSHA256))
+		line += " sig=" + base64.StdEncoding.EncodeToString(sig)
+	}
+	return line + "\n"
+}
+
+// chainMessage is what a link's signature covers: the previous
+// file's name as well as its hash.
+func chainMessage(prev, sum string) []byte {
+	return []byte("logroller chain " + prev + " " + sum)
+}
+
+// chainLinkFields holds a parsed link line.
+type chainLinkFields struct {
+	prev, sum, sig string
+}
+
+// parseChainLink parses a link line, reporting false if line is not
+// one.
+func parseChainLink(line string) (chainLinkFields, bool) {
+	var c chainLinkFields
+	if !strings.HasPrefix(line, chainLinkPrefix+" ") {
+		return c, false
+	}
+	for _, kv := range strings.Fields(line[len(chainLinkPrefix):]) {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+		switch kv[:i] {
+		case "prev":
+			c.prev = kv[i+1:]
+		case "sha256":
+			c.sum = kv[i+1:]
+		case "sig":
+			c.sig = kv[i+1:]
+		}
+	}
+	return c, true
+}
+
+// ChainReport is the result of VerifyChain.
+type ChainReport struct {
+	// Files lists the files checked, oldest first, ending with
+	// the current log file.
+	Files []string
+
+	// BrokenAt is the first file whose link does not match the
+	// file before it, or empty if the chain is intact.
+	BrokenAt string
+
+	// Reason says what is wrong with the link at BrokenAt.
+	Reason string
+}
+
+// Intact reports whether every link checked out.
+func (r *ChainReport) Intact() bool {
+	return r.BrokenAt == ""
+}
+
+// VerifyChain walks the HashChain from the oldest archive still
+// present to the current log file, re-hashing the contents of each
+// file (decompressed) and checking it against the link at the top of
+// the next. If pub is not nil, every link must also carry a valid
+// signature by the matching SigningKey. The oldest file's own link
+// cannot be checked against its predecessor, which may have been
+// deleted, but its signature is.
+func (l *Logger) VerifyChain(pub ed25519.PublicKey) (*ChainReport, error) {
+	files, err := l.logFilesBetween(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	r := &ChainReport{Files: files}
+
+	var prevName, prevSum string
+	for i, name := range files {
+		link, hasLink, sum, err := l.readChainFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var reason string
+		switch {
+		case !hasLink:
+			reason = "no chain link"
+		case i > 0 && link.prev != prevName:
+			reason = fmt.Sprintf("link names previous file %q, expected %q", link.prev, prevName)
+		case i > 0 && link.sum != prevSum:
+			reason = fmt.Sprintf("link has sha256 %s, but %s hashes to %s", link.sum, prevName, prevSum)
+		case pub != nil && link.sum != "":
+			reason = checkChainSig(pub, link)
+		}
+		if reason != "" && !(i == 0 && !hasLink) {
+			r.BrokenAt = name
+			r.Reason = reason
+			return r, nil
+		}
+		prevName = stripArchiveExt(filepath.Base(name))
+		prevSum = sum
+	}
+	return r, nil
+}
+
+func checkChainSig(pub ed25519.PublicKey, link chainLinkFields) string {
+	if link.sig == "" {
+		return "link is not signed"
+	}
+	sig, err := base64.StdEncoding.DecodeString(link.sig)
+	if err != nil || !ed25519.Verify(pub, chainMessage(link.prev, link.sum), sig) {
+		return "bad signature"
+	}
+	return ""
+}
+
+// readChainFile returns the link at the top of the named log file,
+// and the hex SHA-256 of its decompressed contents.
+func (l *Logger) readChainFile(name string) (link chainLinkFields, hasLink bool, sum string, err error) {
+	r, closers, err := l.openLogFile(name)
+	if err != nil {
+		return link, false, "", err
+	}
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+
+	h := sha256.New()
+	br := bufio.NewReader(io.TeeReader(r, h))
+	first, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return link, false, "", err
+	}
+	link, hasLink = parseChainLink(strings.TrimSuffix(first, "\n"))
+	if _, err := io.Copy(ioutil.Discard, br); err != nil {
+		return link, false, "", err
+	}
+	return link, hasLink, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,84 @@
+package logroller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashChain(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestHashChain", t)
+	defer os.RemoveAll(tmp)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	isNil(err, t)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 1000,
+		HashChain:    true,
+		SigningKey:   priv,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+	_, err = l.Write([]byte("d\n"))
+	isNil(err, t)
+
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	link, ok := parseChainLink(strings.SplitN(string(got), "\n", 2)[0])
+	assert(ok, t, "no chain link at top of %q", got)
+	equals(filepath.Base(backupFile(adir)), link.prev, t)
+
+	// compression doesn't break the chain, which covers contents.
+	l.compressLogs(false)
+
+	r, err := l.VerifyChain(pub)
+	isNil(err, t)
+	equals(4, len(r.Files), t)
+	assert(r.Intact(), t, "expected intact chain, got %+v", r)
+
+	// the wrong key is caught at the first signed link; the first
+	// file's link has no predecessor to sign.
+	other, _, err := ed25519.GenerateKey(nil)
+	isNil(err, t)
+	r, err = l.VerifyChain(other)
+	isNil(err, t)
+	equals(r.Files[1], r.BrokenAt, t)
+
+	// so is an altered archive, at the link after it.
+	rd, closers, err := l.openLogFile(r.Files[1])
+	isNil(err, t)
+	orig, err := ioutil.ReadAll(rd)
+	isNil(err, t)
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+	isNil(ioutil.WriteFile(r.Files[1], gzipped(string(orig)+"forged\n"), 0644), t)
+	r, err = l.VerifyChain(pub)
+	isNil(err, t)
+	equals(r.Files[2], r.BrokenAt, t)
+}
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
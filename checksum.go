@@ -0,0 +1,155 @@
+package logroller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const checksumExtension = ".sha256"
+
+// VerifyReport is the result of Verify. Each list holds base names
+// of files in the archive directory.
+type VerifyReport struct {
+	// OK lists the archives whose contents match every checksum
+	// recorded for them.
+	OK []string
+
+	// Modified lists the archives whose contents do not match a
+	// checksum recorded for them.
+	Modified []string
+
+	// Missing lists the archives that have a checksum recorded, in
+	// a sidecar or the manifest, but are no longer present.
+	Missing []string
+
+	// Orphaned lists the archives that have no checksum recorded.
+	Orphaned []string
+}
+
+// Intact reports whether every archive was verified.
+func (r *VerifyReport) Intact() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Orphaned) == 0
+}
+
+// Verify re-hashes every archive in the archive directory and checks
+// it against the checksums recorded in its .sha256 sidecar and, when
+// KeepManifest is set, in the manifest. Run it when no compression
+// is under way, or a file caught mid-compression may be reported.
+func (l *Logger) Verify() (*VerifyReport, error) {
+	expected, err := l.recordedChecksums()
+	if err != nil {
+		return nil, err
+	}
+	files, err := l.allArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &VerifyReport{}
+	for _, f := range files {
+		name := f.Name()
+		sums, ok := expected[name]
+		delete(expected, name)
+		if !ok {
+			r.Orphaned = append(r.Orphaned, name)
+			continue
+		}
+		sum, _, _, err := hashFile(filepath.Join(l.archiveDir(), name))
+		if err != nil {
+			return nil, err
+		}
+		if allEqual(sums, sum) {
+			r.OK = append(r.OK, name)
+		} else {
+			r.Modified = append(r.Modified, name)
+		}
+	}
+	for name := range expected {
+		r.Missing = append(r.Missing, name)
+	}
+
+	sort.Strings(r.OK)
+	sort.Strings(r.Modified)
+	sort.Strings(r.Missing)
+	sort.Strings(r.Orphaned)
+	return r, nil
+}
+
+// recordedChecksums returns every checksum recorded for the archives,
+// by base name, from the sidecars and the manifest.
+func (l *Logger) recordedChecksums() (map[string][]string, error) {
+	expected := make(map[string][]string)
+
+	files, err := ioutil.ReadDir(l.archiveDir())
+	if err != nil {
+		return nil, fmt.Errorf("can't read log file directory: %s", err)
+	}
+	prefix, ext := l.prefixAndExt()
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, checksumExtension) {
+			continue
+		}
+		archive := strings.TrimSuffix(name, checksumExtension)
+		if l.timeFromName(stripArchiveExt(archive), prefix, ext) == "" {
+			continue
+		}
+		sum, err := readChecksum(filepath.Join(l.archiveDir(), name))
+		if err != nil {
+			return nil, err
+		}
+		expected[archive] = append(expected[archive], sum)
+	}
+
+	if l.KeepManifest {
+		m, err := l.ReadManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range m.Archives {
+			expected[e.Name] = append(expected[e.Name], e.SHA256)
+		}
+	}
+	return expected, nil
+}
+
+// writeChecksum writes the sha256sum-style sidecar for archive.
+func writeChecksum(archive, sum string) error {
+	name := archive + checksumExtension
+	tmp := name + ".tmp"
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(archive))
+	if err := ioutil.WriteFile(tmp, []byte(line), 0644); err != nil {
+		return fmt.Errorf("can't write checksum file: %s", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("can't rename checksum file: %s", err)
+	}
+	return nil
+}
+
+// readChecksum returns the hex sum from a sidecar written by
+// writeChecksum.
+func readChecksum(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("can't read checksum file: %s", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file %s", name)
+	}
+	return fields[0], nil
+}
+
+func allEqual(sums []string, sum string) bool {
+	for _, s := range sums {
+		if s != sum {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumsAndVerify(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestChecksumsAndVerify", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 100,
+		Checksums:    true,
+		KeepManifest: true,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+
+	// the newest archive, "c", has a sidecar.
+	newest := backupFile(adir)
+	sum, err := readChecksum(newest + checksumExtension)
+	isNil(err, t)
+	exp, _, _, err := hashFile(newest)
+	isNil(err, t)
+	equals(exp, sum, t)
+
+	l.compressLogs(false)
+	exists(backupFileCompressed(adir)+checksumExtension, t)
+	notExist(newest+checksumExtension, t)
+
+	r, err := l.Verify()
+	isNil(err, t)
+	equals(3, len(r.OK), t)
+	assert(r.Intact(), t, "expected intact archives, got %+v", r)
+
+	files, err := l.oldLogFiles(true)
+	isNil(err, t)
+	equals(3, len(files), t)
+
+	// tamper with one, delete another, and leave an orphan.
+	isNil(ioutil.WriteFile(filepath.Join(adir, files[0].Name()), []byte("x"), 0644), t)
+	isNil(os.Remove(filepath.Join(adir, files[1].Name())), t)
+	orphan := "foobar-2001-01-01T00:00:00Z.log"
+	isNil(ioutil.WriteFile(filepath.Join(adir, orphan), []byte("x"), 0644), t)
+
+	r, err = l.Verify()
+	isNil(err, t)
+	equals([]string{files[2].Name()}, r.OK, t)
+	equals([]string{files[0].Name()}, r.Modified, t)
+	equals([]string{files[1].Name()}, r.Missing, t)
+	equals([]string{orphan}, r.Orphaned, t)
+	assert(!r.Intact(), t, "expected damage to be reported")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/glycerine/logroller"
+)
+
+func runLs(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	l := configFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	as, err := l.Archives()
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	for _, a := range as {
+		fmt.Fprintf(w, "%s %12d %s\n", a.Rotated.Format(time.RFC3339Nano), a.Size, a.Path)
+	}
+	return nil
+}
+
+func runCat(args []string) error {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	l := configFlags(fs)
+	var from, to timeFlag
+	fs.Var(&from, "from", "only print files that may hold writes from this `time` on")
+	fs.Var(&to, "to", "only print files that may hold writes up to this `time`")
+	strip := fs.Bool("strip-preamble", false, "skip the replayed preamble at the top of each rotated file")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	r := logroller.NewReader(l, from.t, to.t)
+	r.StripPreamble = *strip
+	defer r.Close()
+	_, err := io.Copy(stdout, r)
+	return err
+}
+
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	l := configFlags(fs)
+	fs.IntVar(&l.MaxBackups, "max-backups", 0, "keep at most `n` rotated files")
+	fs.IntVar(&l.MaxAge, "max-age", 0, "delete rotated files older than this many `days`")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if err := detectRecords(l); err != nil {
+		return err
+	}
+	if l.MaxBackups == 0 && l.MaxAge == 0 {
+		return errors.New("nothing to do without -max-backups or -max-age")
+	}
+	deleted, err := l.Prune()
+	for _, name := range deleted {
+		fmt.Println("deleted", name)
+	}
+	return err
+}
+
+func runCompress(args []string) error {
+	fs := flag.NewFlagSet("compress", flag.ExitOnError)
+	l := configFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if err := detectRecords(l); err != nil {
+		return err
+	}
+	return l.Compress()
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	l := configFlags(fs)
+	chain := fs.Bool("chain", false, "also walk the hash chain")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if err := detectRecords(l); err != nil {
+		return err
+	}
+	r, err := l.Verify()
+	if err != nil {
+		return err
+	}
+	for _, list := range []struct {
+		label string
+		names []string
+	}{
+		{"ok", r.OK},
+		{"MODIFIED", r.Modified},
+		{"MISSING", r.Missing},
+		{"ORPHANED", r.Orphaned},
+	} {
+		for _, name := range list.names {
+			fmt.Printf("%-8s %s\n", list.label, name)
+		}
+	}
+	intact := r.Intact()
+	if *chain {
+		c, err := l.VerifyChain(nil)
+		if err != nil {
+			return err
+		}
+		if !c.Intact() {
+			fmt.Printf("chain broken at %s: %s\n", c.BrokenAt, c.Reason)
+			intact = false
+		}
+	}
+	if !intact {
+		return errors.New("verification failed")
+	}
+	return nil
+}
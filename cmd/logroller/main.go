@@ -0,0 +1,182 @@
+// Command logroller works with the log files written by a
+// logroller.Logger, from the command line.
+//
+// Usage:
+//
+//	logroller -file name [-max-size 50MB] [-max-backups n] [-compress] < input
+//	logroller run -file name [-stderr-file name] [-max-size 50MB] ... -- command [args...]
+//	logroller ls [-file name] [-archive-dir dir]
+//	logroller cat [-file name] [-archive-dir dir] [-from t] [-to t] [-strip-preamble]
+//	logroller prune [-file name] [-archive-dir dir] [-max-backups n] [-max-age days]
+//	logroller compress [-file name] [-archive-dir dir]
+//	logroller verify [-file name] [-archive-dir dir] [-chain]
+//	logroller search [-file name] [-archive-dir dir] [-from t] [-to t] pattern
+//
+// The run command starts the command, logging its standard output
+// and standard error, forwards signals to it, and exits with its exit
+// code. The command line and start time head every log file.
+//
+// The commands other than the pipe mode and run work on the files an
+// existing configuration has left behind, and should not be used
+// while a writer is running. Give -key-file to read or write
+// encrypted archives. Compress, prune and verify use the .sha256
+// sidecars and the manifest whenever the archives have them, and
+// keep them up to date.
+//
+// Given flags but no command, logroller copies its standard input
+// into the log file, rotating it like a Logger would, in the manner
+// of Apache's rotatelogs. It rotates on SIGHUP, and on EOF, SIGTERM
+// or an interrupt it writes out what it has and exits.
+//
+// Times are given in RFC 3339 format, for example 2016-11-04T18:30:00Z.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glycerine/logroller"
+)
+
+// stdout is where the commands print their results, so that tests
+// can capture them.
+var stdout io.Writer = os.Stdout
+
+// command is one logroller subcommand.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"cat":      {"print the archives and current log, oldest first", runCat},
+	"compress": {"compress any archives not yet compressed", runCompress},
+	"ls":       {"list the archives, oldest first", runLs},
+	"prune":    {"delete archives beyond the retention limits", runPrune},
+	"run":      {"run a command, logging its output", runRun},
+	"search":   {"print the lines matching a pattern, across archives", runSearch},
+	"verify":   {"check the archives against their recorded checksums", runVerify},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if strings.HasPrefix(name, "-") {
+		if err := runPipe(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "logroller: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "logroller: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "logroller %s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: logroller -file name [flags] < input\n")
+	fmt.Fprintf(os.Stderr, "       logroller <command> [flags] [args]\n\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// configFlags adds the flags that locate an existing set of log
+// files to fs, and returns the Logger they configure.
+func configFlags(fs *flag.FlagSet) *logroller.Logger {
+	l := &logroller.Logger{}
+	fs.StringVar(&l.Filename, "file", "", "the current log `file`, as given to Logger.Filename")
+	fs.StringVar(&l.ArchiveDir, "archive-dir", "", "the archive `dir`ectory, if not file.rotated")
+	fs.Var(keyFlag{l}, "key-file", "encrypt archives with the hex AES-256 key in this `file`")
+	fs.BoolVar(&l.Checksums, "checksums", false, "keep .sha256 sidecars for the archives (default on if any exist)")
+	fs.BoolVar(&l.KeepManifest, "manifest", false, "keep the archive manifest (default on if it exists)")
+	return l
+}
+
+// detectRecords turns on Checksums and KeepManifest when the
+// archives already have sidecars or a manifest, so that the commands
+// that change or check the archives keep them in step.
+func detectRecords(l *logroller.Logger) error {
+	as, err := l.Archives()
+	if err != nil || len(as) == 0 {
+		return err
+	}
+	if !l.Checksums {
+		sidecars, err := filepath.Glob(filepath.Join(filepath.Dir(as[0].Path), "*.sha256"))
+		if err != nil {
+			return err
+		}
+		l.Checksums = len(sidecars) > 0
+	}
+	if !l.KeepManifest {
+		m, err := l.ReadManifest()
+		if err != nil {
+			return err
+		}
+		l.KeepManifest = len(m.Archives) > 0
+	}
+	return nil
+}
+
+// keyFlag is a flag.Value that sets a Logger's Encryption from a
+// file holding a hex-encoded 32 byte key.
+type keyFlag struct {
+	l *logroller.Logger
+}
+
+func (f keyFlag) String() string {
+	return ""
+}
+
+func (f keyFlag) Set(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("%s does not hold a hex-encoded 32 byte key", name)
+	}
+	f.l.Encryption = logroller.StaticKey(key)
+	return nil
+}
+
+// timeFlag is a flag.Value holding an optional RFC 3339 time.
+type timeFlag struct {
+	t time.Time
+}
+
+func (f *timeFlag) String() string {
+	if f.t.IsZero() {
+		return ""
+	}
+	return f.t.Format(time.RFC3339Nano)
+}
+
+func (f *timeFlag) Set(s string) (err error) {
+	f.t, err = time.Parse(time.RFC3339Nano, s)
+	return err
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/glycerine/logroller"
+)
+
+func TestSizeFlag(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int64
+	}{
+		{"123", 123},
+		{"50MB", 50 << 20},
+		{"50MiB", 50 << 20},
+		{"50m", 50 << 20},
+		{"1G", 1 << 30},
+		{"8K", 8 << 10},
+	} {
+		var f sizeFlag
+		if err := f.Set(tt.in); err != nil || int64(f) != tt.want {
+			t.Errorf("Set(%q) = %d, %v; want %d", tt.in, f, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "0", "-5M", "MB", "1.5G", "10X", "ten"} {
+		var f sizeFlag
+		if err := f.Set(bad); err == nil {
+			t.Errorf("Set(%q) = %d, want an error", bad, f)
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestPipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var reasons []logroller.RotationReason
+	l := &logroller.Logger{
+		Filename:   filepath.Join(dir, "app.log"),
+		LineAtomic: true,
+		Header: func(info logroller.RotationInfo) []byte {
+			reasons = append(reasons, info.Reason)
+			return nil
+		},
+	}
+	in, stdin := io.Pipe()
+	sigs := make(chan os.Signal)
+	done := make(chan error, 1)
+	go func() {
+		done <- pipe(l, in, sigs)
+	}()
+
+	stdin.Write([]byte("one\n"))
+	waitFor(t, func() bool {
+		data, _ := ioutil.ReadFile(l.Filename)
+		return string(data) == "one\n"
+	})
+	sigs <- syscall.SIGHUP
+	waitFor(t, func() bool {
+		as, err := l.Archives()
+		return err == nil && len(as) == 1
+	})
+
+	// the partial last line is written out at EOF.
+	stdin.Write([]byte("two\nthree"))
+	stdin.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(l.Filename)
+	if err != nil || string(data) != "two\nthree" {
+		t.Fatalf("log holds %q, %v", data, err)
+	}
+	as, err := l.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(as[0].Path)
+	if err != nil || string(data) != "one\n" {
+		t.Fatalf("archive holds %q, %v", data, err)
+	}
+	if len(reasons) != 2 || reasons[1] != logroller.RotateSignal {
+		t.Fatalf("rotation reasons %v, want [startup signal]", reasons)
+	}
+}
+
+// waitFor polls cond until it is true, failing the test after a few
+// seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestArchiveCommands(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		checksums, manifest bool
+	}{
+		{"sidecars", true, false},
+		{"manifest", false, true},
+		{"both", true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "TestArchiveCommands")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			l := &logroller.Logger{
+				Filename:     filepath.Join(dir, "app.log"),
+				Checksums:    tt.checksums,
+				KeepManifest: tt.manifest,
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := l.Write([]byte("line\n")); err != nil {
+					t.Fatal(err)
+				}
+				// rotated files are named to the millisecond.
+				time.Sleep(2 * time.Millisecond)
+				if err := l.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			file := []string{"-file", l.Filename}
+
+			if err := runVerify(file); err != nil {
+				t.Fatal(err)
+			}
+			if err := runCompress(file); err != nil {
+				t.Fatal(err)
+			}
+			if err := runVerify(file); err != nil {
+				t.Fatalf("after compress: %s", err)
+			}
+			if err := runPrune(append(file, "-max-backups", "1")); err != nil {
+				t.Fatal(err)
+			}
+			if err := runVerify(file); err != nil {
+				t.Fatalf("after prune: %s", err)
+			}
+
+			m, err := l.ReadManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sidecars, err := filepath.Glob(filepath.Join(dir, "app.log.rotated", "*.sha256"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[bool]int{true: 1, false: 0}
+			if len(m.Archives) != want[l.KeepManifest] || len(sidecars) != want[l.Checksums] {
+				t.Fatalf("%d manifest entries and sidecars %q left", len(m.Archives), sidecars)
+			}
+		})
+	}
+}
+
+// captureStdout runs f, returning what it printed to stdout.
+func captureStdout(t *testing.T, f func() error) string {
+	t.Helper()
+	var buf bytes.Buffer
+	stdout = &buf
+	defer func() { stdout = os.Stdout }()
+	if err := f(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestSearch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &logroller.Logger{Filename: filepath.Join(dir, "app.log")}
+	if _, err := l.Write([]byte("error one\nfine\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Write([]byte("fine\nerror two\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	as, err := l.Archives()
+	if err != nil || len(as) != 1 {
+		t.Fatalf("archives %v, %v", as, err)
+	}
+
+	got := captureStdout(t, func() error {
+		return runSearch([]string{"-file", l.Filename, "^error"})
+	})
+	want := as[0].Path + ":1: error one\n" + l.Filename + ":2: error two\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLsCat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestLsCat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &logroller.Logger{
+		Filename: filepath.Join(dir, "app.log"),
+		Preamble: []string{"version 1\n"},
+	}
+	for _, s := range []string{"one\n", "two\n"} {
+		if _, err := l.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+		if err := l.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.Write([]byte("three\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	as, err := l.Archives()
+	if err != nil || len(as) != 2 {
+		t.Fatalf("archives %v, %v", as, err)
+	}
+	file := []string{"-file", l.Filename}
+
+	got := captureStdout(t, func() error { return runLs(file) })
+	var want string
+	for _, a := range as {
+		want += fmt.Sprintf("%s %12d %s\n", a.Rotated.Format(time.RFC3339Nano), a.Size, a.Path)
+	}
+	if got != want {
+		t.Fatalf("ls: got %q, want %q", got, want)
+	}
+
+	got = captureStdout(t, func() error { return runCat(append(file, "-strip-preamble")) })
+	if want := "one\ntwo\nthree\n"; got != want {
+		t.Fatalf("cat: got %q, want %q", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestRun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	code := -1
+	osExit = func(c int) { code = c }
+	defer func() { osExit = os.Exit }()
+
+	out, errs := filepath.Join(dir, "out.log"), filepath.Join(dir, "err.log")
+	script := `echo out; i=0; while [ $i -lt 100 ]; do echo error-line-$i >&2; i=$((i+1)); done; exit 3`
+	err = runRun([]string{"-file", out, "-stderr-file", errs, "-max-size", "1K",
+		"-checksums", "-manifest", "--", "sh", "-c", script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("exit code %d, want 3", code)
+	}
+
+	// the standard error log rotates and keeps its records as -file
+	// would.
+	el := &logroller.Logger{Filename: errs}
+	m, err := el.ReadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecars, err := filepath.Glob(filepath.Join(dir, "err.log.rotated", "*.sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Archives) == 0 || len(sidecars) != len(m.Archives) {
+		t.Fatalf("%d manifest entries and sidecars %q", len(m.Archives), sidecars)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, []byte("\nout\n")) {
+		t.Fatalf("standard output log %q", data)
+	}
+}
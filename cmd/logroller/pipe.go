@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/glycerine/logroller"
+)
+
+// runPipe copies stdin into a Logger until EOF, rotating on SIGHUP
+// and stopping cleanly on SIGTERM or an interrupt.
+func runPipe(args []string) error {
+	fs := flag.NewFlagSet("logroller", flag.ExitOnError)
+	l := configFlags(fs)
+	maxSize := rotationFlags(fs, l)
+	fs.IntVar(&l.PreambleLineCount, "preamble-lines", 0, "copy the first `n` lines to the top of every new file")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	if l.Filename == "" {
+		return errors.New("-file is required")
+	}
+	l.MaxSizeBytes = int(*maxSize)
+	// a line split across two reads of stdin should not be split
+	// across two files.
+	l.LineAtomic = true
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+	return pipe(l, os.Stdin, sigs)
+}
+
+// pipe copies in into l until EOF, rotating l on SIGHUP and closing
+// it on any other signal received from sigs.
+func pipe(l *logroller.Logger, in io.Reader, sigs <-chan os.Signal) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(l, in)
+		done <- err
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			if cerr := l.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		case sig := <-sigs:
+			if sig != syscall.SIGHUP {
+				return l.Close()
+			}
+			if err := l.RotateWithReason(logroller.RotateSignal); err != nil {
+				fmt.Fprintf(os.Stderr, "logroller: can't rotate: %s\n", err)
+			}
+		}
+	}
+}
+
+// rotationFlags adds the flags that say when to rotate and what to
+// keep to fs, setting them on l, except for the maximum size, which
+// is returned.
+func rotationFlags(fs *flag.FlagSet, l *logroller.Logger) *sizeFlag {
+	var maxSize sizeFlag
+	fs.Var(&maxSize, "max-size", "rotate when the log reaches this `size`, such as 50MB (default 100MB)")
+	fs.IntVar(&l.MaxBackups, "max-backups", 0, "keep at most `n` rotated files (default all)")
+	fs.IntVar(&l.MaxAge, "max-age", 0, "delete rotated files older than this many `days` (default never)")
+	fs.BoolVar(&l.CompressBackups, "compress", false, "gzip rotated files")
+	fs.BoolVar(&l.LocalTime, "local-time", false, "use local time rather than UTC in rotated file names")
+	return &maxSize
+}
+
+// sizeFlag is a flag.Value holding a size in bytes, given as a
+// number with an optional K, M or G suffix, in binary units.
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*f), 10)
+}
+
+func (f *sizeFlag) Set(s string) error {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	num = strings.TrimSuffix(strings.TrimSuffix(num, "B"), "I")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(num, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(num, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(num, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("bad size %q", s)
+	}
+	*f = sizeFlag(n * mult)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+
+	"github.com/glycerine/logroller"
+)
+
+// osExit is os.Exit, unless a test is catching the exit code.
+var osExit = os.Exit
+
+// runRun supervises a child process, logging its output. It exits
+// with the child's exit code rather than returning.
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	l := configFlags(fs)
+	maxSize := rotationFlags(fs, l)
+	errFile := fs.String("stderr-file", "", "log the child's standard error to this `file` rather than -file")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("expected a command to run")
+	}
+	if l.Filename == "" {
+		return errors.New("-file is required")
+	}
+	l.MaxSizeBytes = int(*maxSize)
+
+	errs := l
+	if *errFile != "" {
+		// the same settings as -file, from every flag.
+		errs = &logroller.Logger{
+			Filename:        *errFile,
+			ArchiveDir:      l.ArchiveDir,
+			Encryption:      l.Encryption,
+			Checksums:       l.Checksums,
+			KeepManifest:    l.KeepManifest,
+			MaxSizeBytes:    l.MaxSizeBytes,
+			MaxBackups:      l.MaxBackups,
+			MaxAge:          l.MaxAge,
+			CompressBackups: l.CompressBackups,
+			LocalTime:       l.LocalTime,
+		}
+		defer errs.Close()
+	}
+	defer l.Close()
+
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	code, err := logroller.Supervise(cmd, l, errs)
+	if err != nil {
+		return err
+	}
+	l.Close()
+	errs.Close()
+	osExit(code)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/glycerine/logroller"
+)
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	l := configFlags(fs)
+	var from, to timeFlag
+	fs.Var(&from, "from", "only search files that may hold writes from this `time` on")
+	fs.Var(&to, "to", "only search files that may hold writes up to this `time`")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("expected exactly one pattern")
+	}
+	re, err := regexp.Compile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	return l.SearchFunc(re, from.t, to.t, func(m logroller.Match) error {
+		_, err := fmt.Fprintf(w, "%s:%d: %s\n", m.File, m.Line, m.Text)
+		return err
+	})
+}
@@ -0,0 +1,141 @@
+package logroller
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const lineSeqSidecarExtension = ".seq"
+
+// decorating reports whether lines get a LineSeq or LineTimeFormat
+// prefix.
+func (l *Logger) decorating() bool {
+	return l.LineSeq || l.LineTimeFormat != ""
+}
+
+// decorate returns line with the configured prefixes.
+func (l *Logger) decorate(line []byte) []byte {
+	out := make([]byte, 0, len(line)+48)
+	if l.LineSeq {
+		l.lineSeq++
+		out = strconv.AppendInt(out, l.lineSeq, 10)
+		out = append(out, ' ')
+	}
+	if l.LineTimeFormat != "" {
+		t := currentTime()
+		if !l.LocalTime {
+			t = t.UTC()
+		}
+		out = t.AppendFormat(out, l.LineTimeFormat)
+		out = append(out, ' ')
+	}
+	return append(out, line...)
+}
+
+// initLineSeq picks up the LineSeq numbering where an earlier run
+// left it, the first time it is called.
+func (l *Logger) initLineSeq() error {
+	if !l.LineSeq || l.lineSeqInit {
+		return nil
+	}
+	l.lineSeq = l.lastLineSeq()
+	l.lineSeqInit = true
+	// mark the file as numbered from here on, so that a restart
+	// after a crash can trust the numbers in it.
+	if err := l.saveLineSeq(); err != nil {
+		l.lineSeqInit = false
+		return err
+	}
+	return nil
+}
+
+// lastLineSeq returns the last LineSeq number written by an earlier
+// run: the number recorded in the sidecar file at its last rotation
+// or Close, carried on through any lines of the current file that
+// continue the sequence, in case that run did not close cleanly. A
+// number that merely starts a line is not trusted on its own, since
+// it may be part of the message, so with no sidecar file, which
+// means LineSeq was never on, the sequence starts afresh.
+func (l *Logger) lastLineSeq() int64 {
+	data, err := ioutil.ReadFile(l.lineSeqFilename())
+	if err != nil {
+		return 0
+	}
+	last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	// what am I going to do, log this?
+	last, _ = continueLineSeq(l.filename(), last)
+	return last
+}
+
+// continueLineSeq returns last advanced past each line of the named
+// file that starts with the next number in the sequence.
+func continueLineSeq(name string, last int64) (int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return last, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadSlice('\n')
+		if n, ok := leadingSeq(line); ok && n == last+1 {
+			last = n
+		}
+		for err == bufio.ErrBufferFull {
+			// skip the rest of a long line.
+			_, err = br.ReadSlice('\n')
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return last, err
+		}
+	}
+}
+
+// lineSeqFilename returns the name of the sidecar file that records
+// the LineSeq number reached.
+func (l *Logger) lineSeqFilename() string {
+	return l.filename() + lineSeqSidecarExtension
+}
+
+// saveLineSeq records the LineSeq number reached in the sidecar
+// file, if LineSeq has numbered anything.
+func (l *Logger) saveLineSeq() error {
+	if !l.lineSeqInit {
+		return nil
+	}
+	name := l.lineSeqFilename()
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(l.lineSeq, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("can't write line sequence file: %s", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("can't rename line sequence file: %s", err)
+	}
+	return nil
+}
+
+// leadingSeq parses the digits at the start of line, if a space
+// follows them.
+func leadingSeq(line []byte) (int64, bool) {
+	i := 0
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	if i == 0 || i >= len(line) || line[i] != ' ' {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(line[:i]), 10, 64)
+	return n, err == nil
+}
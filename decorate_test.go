@@ -0,0 +1,145 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDecorate(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestDecorate", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:        logFile(tmp),
+		LineSeq:         true,
+		LineTimeFormat:  time.RFC3339,
+		CompressBackups: true,
+	}
+	defer l.Close()
+
+	stamp := func() string { return fakeTime().UTC().Format(time.RFC3339) }
+	_, err := l.Write([]byte("a\nb"))
+	isNil(err, t)
+	_, err = l.Write([]byte("\n"))
+	isNil(err, t)
+	first := "1 " + stamp() + " a\n2 " + stamp() + " b\n"
+	existsWithContent(l.Filename, []byte(first), t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	_, err = l.Write([]byte("c\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("3 "+stamp()+" c\n"), t)
+	isNil(l.Close(), t)
+
+	// a restart picks up where we left off, even once the current
+	// file holds only what the last run's rotation left in it.
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	isNil(l.Close(), t)
+	<-time.After(10 * time.Millisecond) // compression runs on another goroutine.
+
+	l2 := &Logger{Filename: l.Filename, LineSeq: true}
+	defer l2.Close()
+	_, err = l2.Write([]byte("d\n"))
+	isNil(err, t)
+	existsWithContent(l2.Filename, []byte("4 d\n"), t)
+}
+
+func TestDecorateNotJSON(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestDecorateNotJSON", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{Filename: logFile(tmp), LineSeq: true, JSONLines: true}
+	defer l.Close()
+	_, err := l.Write([]byte("{}\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("{}\n"), t)
+}
+
+func existsWithContent(path string, content []byte, t testing.TB) {
+	data, err := ioutil.ReadFile(path)
+	isNilUp(err, t, 1)
+	equalsUp(string(content), string(data), t, 1)
+}
+
+func TestLineSeqRestart(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestLineSeqRestart", t)
+	defer os.RemoveAll(tmp)
+
+	// numbers written before LineSeq was on are not sequence numbers.
+	plain := &Logger{Filename: logFile(tmp)}
+	_, err := plain.Write([]byte("404 Not Found\n"))
+	isNil(err, t)
+	isNil(plain.Close(), t)
+
+	l := &Logger{Filename: plain.Filename, LineSeq: true}
+	_, err = l.Write([]byte("a\nb\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("404 Not Found\n1 a\n2 b\n"), t)
+
+	// a run that never closed is picked up from its numbered lines.
+	l2 := &Logger{Filename: l.Filename, LineSeq: true}
+	defer l2.Close()
+	_, err = l2.Write([]byte("c\n"))
+	isNil(err, t)
+	existsWithContent(l2.Filename, []byte("404 Not Found\n1 a\n2 b\n3 c\n"), t)
+	l.close()
+}
+
+func TestLineSeqPreamble(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestLineSeqPreamble", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:          logFile(tmp),
+		LineSeq:           true,
+		PreambleLineCount: 1,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("version 1\nb\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("1 version 1\n2 b\n"), t)
+	equals([]string{"version 1\n"}, l.Preamble, t)
+
+	// the replayed Preamble takes no number, stale or new.
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	_, err = l.Write([]byte("c\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("version 1\n"+endOfPreamble+"3 c\n"), t)
+}
+
+func TestLineSeqSaveError(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestLineSeqSaveError", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{Filename: logFile(tmp), LineSeq: true}
+	defer l.Close()
+
+	// the file can't be marked as numbered through a directory in
+	// the way, so no line is numbered until it can be.
+	dir := l.lineSeqFilename() + ".tmp"
+	isNil(os.Mkdir(dir, 0755), t)
+	n, err := l.Write([]byte("a\n"))
+	notNil(err, t)
+	equals(0, n, t)
+
+	isNil(os.Remove(dir), t)
+	_, err = l.Write([]byte("b\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("1 b\n"), t)
+}
@@ -0,0 +1,38 @@
+package logroller
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// repeated reports whether line, which has been redacted, repeats
+// the last line written within DedupeWindow, and if so counts it.
+func (l *Logger) repeated(line []byte) bool {
+	if l.lastLine != nil && bytes.Equal(line, l.lastLine) &&
+		currentTime().Sub(l.lastLineTime) < l.DedupeWindow {
+		l.repeats++
+		return true
+	}
+	return false
+}
+
+// remember makes line, which has just been written, the one that
+// later lines are compared with.
+func (l *Logger) remember(line []byte) {
+	l.lastLine = append(l.lastLine[:0], line...)
+	l.lastLineTime = currentTime()
+}
+
+// takeRepeats returns the line reporting the repeats counted so far,
+// or nil if there are none, and resets the count.
+func (l *Logger) takeRepeats() []byte {
+	if l.repeats == 0 {
+		return nil
+	}
+	n := l.repeats
+	l.repeats = 0
+	if l.JSONLines {
+		return []byte(fmt.Sprintf("{\"msg\":\"last message repeated %d times\",\"repeated\":%d}\n", n, n))
+	}
+	return []byte(fmt.Sprintf("last message repeated %d times\n", n))
+}
@@ -0,0 +1,104 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupe(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestDedupe", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:     logFile(tmp),
+		DedupeWindow: time.Minute,
+	}
+	defer l.Close()
+
+	write := func(s string) {
+		_, err := l.Write([]byte(s))
+		isNilUp(err, t, 1)
+	}
+	write(strings.Repeat("conn refused\n", 1000))
+	write("ok\nconn refused\nconn refused\n")
+	existsWithContent(l.Filename, []byte("conn refused\nlast message repeated 999 times\n"+
+		"ok\nconn refused\n"), t)
+
+	// the count is flushed into the file it belongs to on rotation.
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	existsWithContent(backupFile(l.archiveDir()), []byte("conn refused\nlast message repeated 999 times\n"+
+		"ok\nconn refused\nlast message repeated 1 times\n"), t)
+
+	// the window is measured from the first of the run.
+	write("conn refused\nconn refused\nconn refused\n")
+	fakeCurrentTime = fakeCurrentTime.Add(time.Minute)
+	write("conn refused\n")
+	isNil(l.Close(), t)
+	existsWithContent(l.Filename, []byte("conn refused\nlast message repeated 2 times\n"+
+		"conn refused\n"), t)
+}
+
+func TestDedupeJSON(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestDedupeJSON", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:     logFile(tmp),
+		DedupeWindow: time.Minute,
+		JSONLines:    true,
+		JSONSeq:      true,
+	}
+	_, err := l.Write([]byte("{}\n{}\n{}\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+	data, err := ioutil.ReadFile(l.Filename)
+	isNil(err, t)
+	equals("{\"seq\":1}\n{\"seq\":2,\"msg\":\"last message repeated 2 times\",\"repeated\":2}\n", string(data), t)
+}
+
+func TestDedupeRotation(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+
+	tmp := makeTempDir("TestDedupeRotation", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:     logFile(tmp),
+		MaxSizeBytes: 20,
+		DedupeWindow: time.Hour,
+	}
+	defer l.Close()
+
+	// the count stays in the file with the line it counts, even when
+	// that file is new and the count takes it past MaxSizeBytes.
+	for _, s := range []string{"0123456789abcdef\n", "boom\nboom\nboom\n", "x\n"} {
+		_, err := l.Write([]byte(s))
+		isNil(err, t)
+	}
+	isNil(l.Rotate(), t)
+	_, err := l.Write([]byte("x\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	var got []string
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := ioutil.ReadFile(filepath.Join(l.archiveDir(), files[i].Name()))
+		isNil(err, t)
+		got = append(got, string(data))
+	}
+	// a repeat after a rotation starts the count afresh.
+	equals([]string{"0123456789abcdef\n", "boom\nlast message repeated 2 times\n", "x\n"}, got, t)
+	existsWithContent(l.Filename, []byte("x\n"), t)
+}
@@ -0,0 +1,267 @@
+package logroller
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	encryptFileExtension = ".enc"
+
+	// encryptMagic starts every encrypted archive.
+	encryptMagic = "LRAESGCM1"
+
+	// encryptChunkSize is the most plaintext sealed in one chunk.
+	encryptChunkSize = 64 * 1024
+)
+
+// KeyProvider supplies the AES-256 keys used to encrypt archives
+// when Logger.Encryption is set. Each archive records the ID of the
+// key that encrypted it, so keys can be rotated while older archives
+// stay readable.
+type KeyProvider interface {
+	// CurrentKey returns the 32 byte key to encrypt new archives
+	// with, and its ID. The ID is stored in the clear.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, for decryption.
+	Key(id string) ([]byte, error)
+}
+
+// StaticKey is a KeyProvider with a single 32 byte key, whose ID is
+// derived from the key itself.
+type StaticKey []byte
+
+// CurrentKey implements KeyProvider.
+func (k StaticKey) CurrentKey() (string, []byte, error) {
+	return k.id(), k, nil
+}
+
+// Key implements KeyProvider.
+func (k StaticKey) Key(id string) ([]byte, error) {
+	if id != k.id() {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return k, nil
+}
+
+func (k StaticKey) id() string {
+	sum := sha256.Sum256(k)
+	return hex.EncodeToString(sum[:8])
+}
+
+// The encrypted format is the magic, a one byte key ID length, the
+// key ID, and a 7 byte random nonce prefix, all of which is
+// authenticated as additional data; followed by chunks, each a 4
+// byte big-endian length and an AES-256-GCM sealed chunk of up to
+// encryptChunkSize bytes of plaintext. A chunk's nonce is the prefix,
+// its 4 byte big-endian index, and a byte that is 1 only for the
+// final chunk, so chunks cannot be reordered, dropped or truncated
+// without detection.
+
+// encryptLog encrypts the named file to filename + ".enc" and
+// removes the original.
+func encryptLog(filename string, keys KeyProvider) error {
+	r, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.Create(filename + encryptFileExtension)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := encryptStream(w, r, keys); err != nil {
+		os.Remove(filename + encryptFileExtension)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// Explicitly closing the r in addition to defer r.Close so that
+	// we don't get 'file is being used by another process' errors on Windows
+	r.Close()
+	return os.Remove(filename)
+}
+
+// encryptStream encrypts all of src to dst.
+func encryptStream(dst io.Writer, src io.Reader, keys KeyProvider) error {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return err
+	}
+	if len(id) > 255 {
+		return errors.New("key id longer than 255 bytes")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	var prefix [7]byte
+	if _, err := rand.Read(prefix[:]); err != nil {
+		return err
+	}
+	header := append([]byte(encryptMagic), byte(len(id)))
+	header = append(header, id...)
+	header = append(header, prefix[:]...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(dst)
+	plain := make([]byte, encryptChunkSize)
+	var sealed []byte
+	var index uint32
+	// read one chunk ahead, so we know which chunk is the last.
+	n, rerr := io.ReadFull(src, plain)
+	for {
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+		chunk := append([]byte(nil), plain[:n]...)
+		final := rerr != nil
+		if !final {
+			n, rerr = io.ReadFull(src, plain)
+			if rerr == io.EOF {
+				final = true
+			}
+		}
+		sealed = aead.Seal(sealed[:0], chunkNonce(prefix, index, final), chunk, header)
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+		bw.Write(size[:])
+		if _, err := bw.Write(sealed); err != nil {
+			return err
+		}
+		if final {
+			return bw.Flush()
+		}
+		index++
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key is %d bytes, want 32 for AES-256", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix [7]byte, index uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix[:])
+	binary.BigEndian.PutUint32(nonce[7:], index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// decryptReader is an io.Reader over the plaintext of an encrypted
+// archive.
+type decryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix [7]byte
+	index  uint32
+	plain  []byte
+	done   bool
+}
+
+// newDecryptReader reads the header of an encrypted archive from src
+// and looks up its key.
+func newDecryptReader(src io.Reader, keys KeyProvider) (*decryptReader, error) {
+	if keys == nil {
+		return nil, errors.New("archive is encrypted, but no KeyProvider is set")
+	}
+	br := bufio.NewReader(src)
+	header := make([]byte, len(encryptMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("short encryption header: %s", err)
+	}
+	if string(header[:len(encryptMagic)]) != encryptMagic {
+		return nil, errors.New("not an encrypted archive")
+	}
+	rest := make([]byte, int(header[len(encryptMagic)])+7)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, fmt.Errorf("short encryption header: %s", err)
+	}
+	header = append(header, rest...)
+	id := string(rest[:len(rest)-7])
+
+	key, err := keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	d := &decryptReader{src: br, aead: aead, header: header}
+	copy(d.prefix[:], rest[len(rest)-7:])
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk.
+func (d *decryptReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(d.src, size[:]); err != nil {
+		return fmt.Errorf("encrypted archive is truncated: %s", err)
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > uint32(encryptChunkSize+d.aead.Overhead()) {
+		return fmt.Errorf("encrypted archive has a bad chunk length %d", n)
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(d.src, sealed); err != nil {
+		return fmt.Errorf("encrypted archive is truncated: %s", err)
+	}
+	// try the chunk as a middle chunk, then as the final one.
+	plain, err := d.aead.Open(nil, chunkNonce(d.prefix, d.index, false), sealed, d.header)
+	if err != nil {
+		plain, err = d.aead.Open(nil, chunkNonce(d.prefix, d.index, true), sealed, d.header)
+		if err != nil {
+			return errors.New("encrypted archive failed authentication")
+		}
+		d.done = true
+		if _, err := d.src.Peek(1); err != io.EOF {
+			return errors.New("encrypted archive has data after its final chunk")
+		}
+	}
+	d.plain = plain
+	d.index++
+	return nil
+}
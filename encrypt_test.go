@@ -0,0 +1,145 @@
+package logroller
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptedArchives(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestEncryptedArchives", t)
+	defer os.RemoveAll(tmp)
+
+	key := StaticKey(bytes.Repeat([]byte{7}, 32))
+	l := &Logger{
+		Filename:        logFile(tmp),
+		MaxSizeBytes:    100,
+		CompressBackups: true,
+		KeepManifest:    true,
+		Checksums:       true,
+		Encryption:      key,
+	}
+	defer l.Close()
+
+	for _, line := range []string{"secret a\n", "secret b\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+	_, err := l.Write([]byte("secret c\n"))
+	isNil(err, t)
+	l.compressLogs(false)
+
+	enc, err := l.archivesWithSuffix(compressFileExtension + encryptFileExtension)
+	isNil(err, t)
+	equals(2, len(enc), t)
+	for _, f := range enc {
+		data, err := ioutil.ReadFile(filepath.Join(l.archiveDir(), f.Name()))
+		isNil(err, t)
+		assert(!bytes.Contains(data, []byte("secret")), t, "archive %s is readable", f.Name())
+	}
+	fileCount(l.archiveDir(), 5, t) // 2 archives, 2 sidecars, manifest
+
+	m, err := l.ReadManifest()
+	isNil(err, t)
+	equals(2, len(m.Archives), t)
+	equals("gzip", m.Archives[0].Compression, t)
+	equals("aes-256-gcm", m.Archives[0].Encryption, t)
+	equals(enc[1].Name(), m.Archives[0].Name, t)
+
+	v, err := l.Verify()
+	isNil(err, t)
+	assert(v.Intact(), t, "expected intact archives, got %+v", v)
+
+	r := NewReader(l, time.Time{}, time.Time{})
+	got, err := ioutil.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("secret a\nsecret b\nsecret c\n", string(got), t)
+
+	// without the key, the archives can't be read.
+	other := &Logger{Filename: l.Filename, Encryption: StaticKey(bytes.Repeat([]byte{8}, 32))}
+	r = NewReader(other, time.Time{}, time.Time{})
+	_, err = ioutil.ReadAll(r)
+	notNil(err, t)
+	r.Close()
+}
+
+func TestEncryptOnly(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestEncryptOnly", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:   logFile(tmp),
+		Encryption: StaticKey(bytes.Repeat([]byte{1}, 32)),
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("a\n"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	l.compressLogs(false)
+
+	enc, err := l.archivesWithSuffix(encryptFileExtension)
+	isNil(err, t)
+	equals(1, len(enc), t)
+	fileCount(l.archiveDir(), 1, t)
+
+	got, err := l.Search("a", time.Time{}, time.Time{})
+	isNil(err, t)
+	equals(1, len(got), t)
+	assert(strings.HasSuffix(got[0].File, encryptFileExtension), t, "expected a match in %s", got[0].File)
+}
+
+func TestEncryptTamper(t *testing.T) {
+	key := StaticKey(bytes.Repeat([]byte{3}, 32))
+	plain := bytes.Repeat([]byte("0123456789abcdef"), encryptChunkSize/8)
+
+	var buf bytes.Buffer
+	isNil(encryptStream(&buf, bytes.NewReader(plain), key), t)
+
+	d, err := newDecryptReader(bytes.NewReader(buf.Bytes()), key)
+	isNil(err, t)
+	got, err := ioutil.ReadAll(d)
+	isNil(err, t)
+	equals(plain, got, t)
+
+	// a flipped bit fails authentication.
+	flipped := append([]byte(nil), buf.Bytes()...)
+	flipped[len(flipped)/2] ^= 1
+	d, err = newDecryptReader(bytes.NewReader(flipped), key)
+	isNil(err, t)
+	_, err = ioutil.ReadAll(d)
+	notNil(err, t)
+
+	// dropping the final chunk is caught too.
+	short := buf.Bytes()[:buf.Len()-(4+encryptChunkSize+16)]
+	d, err = newDecryptReader(bytes.NewReader(short), key)
+	isNil(err, t)
+	_, err = ioutil.ReadAll(d)
+	notNil(err, t)
+
+	// a chunk length past the largest chunk is refused before
+	// anything is allocated for it.
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], encryptChunkSize+16)
+	huge := append([]byte(nil), buf.Bytes()...)
+	i := bytes.Index(huge, size[:])
+	assert(i > 0, t, "no chunk length found")
+	binary.BigEndian.PutUint32(huge[i:], 0xffffffff)
+	d, err = newDecryptReader(bytes.NewReader(huge), key)
+	isNil(err, t)
+	_, err = ioutil.ReadAll(d)
+	notNil(err, t)
+}
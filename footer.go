@@ -0,0 +1,94 @@
+package logroller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	endOfFile   = "___***___END_OF_FILE___***___"
+	startOfFile = "___***___START_OF_FILE___***___"
+)
+
+var newline = []byte{'\n'}
+
+// writeFooter appends the Footer trailer line to the current file,
+// which is about to be closed and moved to info.PreviousFile.
+// Only the base name is recorded, since the archives may be
+// copied elsewhere.
+func (l *Logger) writeFooter(info RotationInfo) error {
+	if !l.Footer || l.file == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if l.size > 0 && !l.endsInNewline() {
+		buf.WriteByte('\n')
+	}
+	t := info.Time
+	if !l.LocalTime {
+		t = t.UTC()
+	}
+	fmt.Fprintf(&buf, "%s time=%s reason=%s bytes=%d lines=%d archived_as=%s\n",
+		endOfFile, t.Format(backupTimeFormat), info.Reason, l.size, l.lines,
+		filepath.Base(info.PreviousFile))
+
+	_, err := l.writeFile(buf.Bytes())
+	return err
+}
+
+// previousLink returns the line that starts a new file when Footer
+// is set, naming the archive of the file before it, or "" if there
+// was none. The name of the file that follows is not known until it
+// too is rotated, so the link is kept here rather than in the
+// footer.
+func (l *Logger) previousLink(info RotationInfo) string {
+	if !l.Footer || info.PreviousFile == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s previous=%s\n", startOfFile, filepath.Base(info.PreviousFile))
+}
+
+// endsInNewline reports whether the last byte written to the
+// current file was a newline.
+func (l *Logger) endsInNewline() bool {
+	f, err := os.Open(l.file.Name())
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, l.size-1); err != nil {
+		return true
+	}
+	return last[0] == '\n'
+}
+
+// scanExisting reads the reopened log file to recover its line
+// count and running checksum.
+func (l *Logger) scanExisting(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = lineCounter{&l.lines}
+	if l.hash != nil {
+		w = io.MultiWriter(w, l.hash)
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// lineCounter is an io.Writer that counts newlines.
+type lineCounter struct {
+	n *int64
+}
+
+func (c lineCounter) Write(p []byte) (int, error) {
+	*c.n += int64(bytes.Count(p, newline))
+	return len(p), nil
+}
@@ -0,0 +1,67 @@
+package logroller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFooter(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestFooter", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 200,
+		Footer:       true,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	_, err := l.Write([]byte("one\ntwo\nthree"))
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.RotateWithReason(RotateSignal), t)
+
+	first := backupFile(adir)
+	got, err := ioutil.ReadFile(first)
+	isNil(err, t)
+	exp := fmt.Sprintf("one\ntwo\nthree\n%s time=%s reason=signal bytes=13 lines=2 archived_as=%s\n",
+		endOfFile, fakeTime().UTC().Format(backupTimeFormat), filepath.Base(first))
+	equals(exp, string(got), t)
+
+	// the new file names the archive before it.
+	hdr := fmt.Sprintf("%s previous=%s\n%s", startOfFile, filepath.Base(first), endOfPreamble)
+	got, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals(hdr, string(got), t)
+
+	// a fresh logger reopening the file keeps counting its lines.
+	isNil(l.Close(), t)
+	_, err = l.Write([]byte("four\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	l2 := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 200,
+		Footer:       true,
+	}
+	defer l2.Close()
+	_, err = l2.Write([]byte("five\n"))
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l2.Rotate(), t)
+	got, err = ioutil.ReadFile(backupFile(adir))
+	isNil(err, t)
+	exp = fmt.Sprintf("%sfour\nfive\n%s time=%s reason=manual bytes=%d lines=4 archived_as=%s\n",
+		hdr, endOfFile, fakeTime().UTC().Format(backupTimeFormat), len(hdr)+10, filepath.Base(backupFile(adir)))
+	equals(exp, string(got), t)
+}
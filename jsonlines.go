@@ -0,0 +1,93 @@
+package logroller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const quarantineExtension = ".quarantine"
+
+// jsonLine checks that line, which ends in a newline, is a JSON
+// object, and returns it with any configured fields added. A line
+// that is not is written to the quarantine file and nil is returned.
+func (l *Logger) jsonLine(line []byte) ([]byte, error) {
+	body := bytes.TrimSpace(line)
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, l.quarantineLine(line)
+	}
+	l.jsonSeq++
+
+	var add []byte
+	if l.JSONTimestamp && fields["ts"] == nil {
+		t := currentTime()
+		if !l.LocalTime {
+			t = t.UTC()
+		}
+		add = append(add, `"ts":`...)
+		add = strconv.AppendQuote(add, t.Format(time.RFC3339Nano))
+		add = append(add, ',')
+	}
+	if l.JSONHost && fields["host"] == nil {
+		if l.hostname == "" {
+			l.hostname, _ = os.Hostname()
+		}
+		add = append(add, `"host":`...)
+		add = strconv.AppendQuote(add, l.hostname)
+		add = append(add, ',')
+	}
+	if l.JSONSeq && fields["seq"] == nil {
+		add = append(add, `"seq":`...)
+		add = strconv.AppendInt(add, l.jsonSeq, 10)
+		add = append(add, ',')
+	}
+	if len(add) == 0 {
+		return line, nil
+	}
+	if len(fields) == 0 {
+		add = add[:len(add)-1]
+	}
+
+	// splice the new fields in after the opening brace, leaving
+	// the rest of the line as it was written.
+	out := make([]byte, 0, len(body)+len(add)+1)
+	out = append(out, '{')
+	out = append(out, add...)
+	out = append(out, body[1:]...)
+	return append(out, '\n'), nil
+}
+
+// quarantineFilename returns the name of the QuarantineFile.
+func (l *Logger) quarantineFilename() string {
+	if l.QuarantineFile != "" {
+		return l.QuarantineFile
+	}
+	return l.filename() + quarantineExtension
+}
+
+// quarantineLine appends line to the quarantine file.
+func (l *Logger) quarantineLine(line []byte) error {
+	if l.quarantine == nil {
+		f, err := os.OpenFile(l.quarantineFilename(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("can't open quarantine file: %s", err)
+		}
+		l.quarantine = f
+	}
+	_, err := l.quarantine.Write(line)
+	return err
+}
+
+// closeQuarantine closes the quarantine file if it is open.
+func (l *Logger) closeQuarantine() error {
+	if l.quarantine == nil {
+		return nil
+	}
+	err := l.quarantine.Close()
+	l.quarantine = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJSONLines(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestJSONLines", t)
+	defer os.RemoveAll(tmp)
+
+	host, err := os.Hostname()
+	isNil(err, t)
+	l := &Logger{
+		Filename:      logFile(tmp),
+		JSONLines:     true,
+		JSONTimestamp: true,
+		JSONHost:      true,
+		JSONSeq:       true,
+	}
+	defer l.Close()
+
+	input := "{\"msg\":\"a\"}\nnot json\n[1,2]\n{}\n{\"msg\":\"b\",\"seq\":99}\n{\"msg\":\"spl"
+	n, err := l.Write([]byte(input))
+	isNil(err, t)
+	equals(len(input), n, t)
+	_, err = l.Write([]byte("it\"}\n{\"broken\":"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	ts := `"ts":"` + fakeTime().UTC().Format(time.RFC3339Nano) + `","host":"` + host + `",`
+	data, err := ioutil.ReadFile(l.Filename)
+	isNil(err, t)
+	equals(`{`+ts+`"seq":1,"msg":"a"}`+"\n"+
+		`{`+ts+`"seq":2}`+"\n"+
+		`{`+ts[:len(ts)-1]+`,"msg":"b","seq":99}`+"\n"+
+		`{`+ts+`"seq":4,"msg":"split"}`+"\n", string(data), t)
+
+	data, err = ioutil.ReadFile(l.Filename + quarantineExtension)
+	isNil(err, t)
+	equals("not json\n[1,2]\n{\"broken\":\n", string(data), t)
+}
+
+func TestJSONLinesPlain(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestJSONLinesPlain", t)
+	defer os.RemoveAll(tmp)
+
+	quarantine := tmp + "/bad.log"
+	l := &Logger{
+		Filename:       logFile(tmp),
+		JSONLines:      true,
+		QuarantineFile: quarantine,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte(" {\"a\": 1}\nnull\n"))
+	isNil(err, t)
+	existsWithLen(l.Filename, len(" {\"a\": 1}\n"), t)
+	existsWithLen(quarantine, len("null\n"), t)
+}
+
+func TestJSONLinesFailures(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestJSONLinesFailures", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:       logFile(tmp),
+		MaxSizeBytes:   20,
+		JSONLines:      true,
+		JSONSeq:        true,
+		QuarantineFile: tmp + "/missing/bad.log",
+	}
+	defer l.Close()
+
+	// a line that can't be quarantined is dropped and the error
+	// reported once.
+	n, err := l.Write([]byte("not json\n"))
+	notNil(err, t)
+	equals(9, n, t)
+
+	// so is a line too long to write, and it takes no seq number.
+	long := "{\"msg\":\"far too long\"}\n"
+	n, err = l.Write([]byte(long))
+	notNil(err, t)
+	equals(len(long), n, t)
+
+	_, err = l.Write([]byte("{\"m\":1}\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	data, err := ioutil.ReadFile(l.Filename)
+	isNil(err, t)
+	equals("{\"seq\":1,\"m\":1}\n", string(data), t)
+}
@@ -0,0 +1,239 @@
+package logroller
+
+import (
+	"bytes"
+)
+
+const defaultMaxPendingBytes = 64 * 1024
+
+// writeLineAtomic appends p to the pending buffer and writes out
+// every complete line it now holds, keeping the incomplete tail
+// for a later Write. Rotation can therefore only occur on a line
+// boundary. The full len(p) is reported as written, since the
+// tail is retained and flushed by a later Write or by Close.
+//
+// Lines leave the buffer as soon as they are written. If a line
+// can't be written, the lines after it in p are not taken, and n
+// counts only the bytes of p before them, so that a retry of p[n:]
+// duplicates nothing. A line that OversizeReject refuses counts as
+// taken: it is dropped, and the error reported once.
+func (l *Logger) writeLineAtomic(p []byte) (n int, err error) {
+	old := len(l.pending)
+	l.pending = append(l.pending, p...)
+
+	if i := bytes.LastIndexByte(l.pending, '\n'); i >= 0 {
+		done, err := l.emitLines(l.pending[:i+1])
+		if err != nil {
+			if done < old {
+				// keep what earlier Writes handed us; p is not taken.
+				rest := copy(l.pending, l.pending[done:old])
+				l.pending = l.pending[:rest]
+				return 0, err
+			}
+			l.pending = l.pending[:0]
+			return done - old, err
+		}
+		rest := copy(l.pending, l.pending[i+1:])
+		l.pending = l.pending[:rest]
+	}
+
+	if int64(len(l.pending)) > l.maxPending() {
+		tail := len(l.pending)
+		if err = l.flushPending(); err != nil {
+			// the tail was dropped.
+			if tail > len(p) {
+				return 0, err
+			}
+			return len(p) - tail, err
+		}
+	}
+	return len(p), nil
+}
+
+// emitLines writes b, which ends in a newline, as a series of
+// batches of whole lines, each small enough to fit in one log
+// file, or line by line when lines are filtered. A single line
+// longer than MaxSizeBytes is left to the OversizeWrites policy.
+// It returns how many bytes of b are done with: written, or
+// dropped because they can never be written.
+func (l *Logger) emitLines(b []byte) (done int, err error) {
+	if l.filtering() {
+		for len(b) > 0 {
+			line := b[:bytes.IndexByte(b, '\n')+1]
+			b = b[len(line):]
+			if drop, err := l.emitLine(line); err != nil {
+				if drop {
+					done += len(line)
+				}
+				return done, err
+			}
+			done += len(line)
+		}
+		return done, nil
+	}
+
+	for len(b) > 0 {
+		batch := b
+		if int64(len(batch)) > l.max() {
+			if i := bytes.LastIndexByte(batch[:l.max()], '\n'); i >= 0 {
+				batch = batch[:i+1]
+			} else {
+				batch = batch[:bytes.IndexByte(batch, '\n')+1]
+			}
+		}
+		if _, err := l.emit(batch); err != nil {
+			if l.rejects(batch) {
+				done += len(batch)
+			}
+			return done, err
+		}
+		done += len(batch)
+		b = b[len(batch):]
+	}
+	return done, nil
+}
+
+// rejects reports whether emit refuses p outright, as it does an
+// oversize write under OversizeReject, so there is no use in
+// trying p again.
+func (l *Logger) rejects(p []byte) bool {
+	return int64(len(p)) > l.max() && l.OversizeWrites == OversizeReject
+}
+
+// flushPending writes out any buffered partial line, as is, unless
+// lines are being filtered, in which case it is filtered as a line of
+// its own. The buffer is emptied even if the write fails.
+func (l *Logger) flushPending() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	var err error
+	if l.filtering() {
+		_, err = l.emitLines(append(l.pending, '\n'))
+	} else {
+		_, err = l.emit(l.pending)
+	}
+	l.pending = l.pending[:0]
+	return err
+}
+
+// lineMode reports whether writes are buffered into whole lines.
+func (l *Logger) lineMode() bool {
+	return l.LineAtomic || l.filtering()
+}
+
+// filtering reports whether each line is checked or rewritten on its
+// way to the file.
+func (l *Logger) filtering() bool {
+	return l.JSONLines || l.decorating() || l.redacting() || l.DedupeWindow > 0 || l.RateLimit != nil
+}
+
+// emitLine passes line, which ends in a newline, through the
+// configured line filters and writes what is left of it. Any lines
+// reporting what the filters dropped before it are written first,
+// straight into the current file, so that they stay with the lines
+// they count even if line then starts a new file. If line can't be
+// written, drop reports whether it is gone for good. The line is
+// captured into the Preamble as it was before JSONLines or the line
+// prefixes changed it, so that a replayed Preamble does not repeat
+// stale sequence numbers or times.
+func (l *Logger) emitLine(line []byte) (drop bool, err error) {
+	if err := l.initLineSeq(); err != nil {
+		return false, err
+	}
+	if l.redacting() {
+		line = l.redact(line)
+	}
+	if l.DedupeWindow > 0 && l.repeated(line) {
+		return false, nil
+	}
+	if l.RateLimit != nil && !l.limit(line) {
+		return false, nil
+	}
+	if err := l.writeSummaries(false); err != nil {
+		return false, err
+	}
+
+	lineSeq, jsonSeq := l.lineSeq, l.jsonSeq
+	out, err := l.finishLine(line)
+	if err != nil {
+		// the quarantine failed; drop the line.
+		return true, err
+	}
+	if out == nil {
+		// quarantined.
+		return false, nil
+	}
+	if _, err := l.emit(out); err != nil {
+		// a line that is not written takes no sequence number.
+		l.lineSeq, l.jsonSeq = lineSeq, jsonSeq
+		return l.rejects(out), err
+	}
+	l.capturePreamble(line)
+	if l.DedupeWindow > 0 {
+		l.remember(line)
+	}
+	return false, nil
+}
+
+// summaryLines returns the lines reporting what DedupeWindow and
+// RateLimit have dropped and not yet reported, ready to write, and
+// resets their counts. Lines suppressed by a RateLimit that is still
+// over its limit, as when a line is let through only as a sample,
+// are left to report later, unless all is set.
+func (l *Logger) summaryLines(all bool) ([]byte, error) {
+	var out []byte
+	summaries := [][]byte{l.takeRepeats()}
+	if all || l.limiter.over == 0 {
+		summaries = append(summaries, l.takeSuppressed())
+	}
+	for _, summary := range summaries {
+		if summary == nil {
+			continue
+		}
+		b, err := l.finishLine(summary)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
+
+// writeSummaries writes out the summaryLines. They go into the
+// current file even if that takes it past MaxSizeBytes, as the
+// Footer does, since the lines they count are in it.
+func (l *Logger) writeSummaries(all bool) error {
+	b, err := l.summaryLines(all)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	if l.file == nil {
+		_, err = l.emit(b)
+		return err
+	}
+	_, err = l.writeFile(b)
+	return err
+}
+
+// finishLine applies the filters that come after redaction,
+// deduplication and rate limiting: JSONLines checks, or else the
+// line prefixes.
+func (l *Logger) finishLine(line []byte) ([]byte, error) {
+	if l.JSONLines {
+		return l.jsonLine(line)
+	}
+	if l.decorating() {
+		return l.decorate(line), nil
+	}
+	return line, nil
+}
+
+// maxPending returns the most bytes of an incomplete line we
+// will buffer in line mode.
+func (l *Logger) maxPending() int64 {
+	if l.MaxPendingBytes == 0 {
+		return int64(defaultMaxPendingBytes)
+	}
+	return int64(l.MaxPendingBytes)
+}
@@ -0,0 +1,121 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineAtomic(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+
+	tmp := makeTempDir("TestLineAtomic", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 12,
+		LineAtomic:   true,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	for _, frag := range []string{"first ", "line\n", "sec", "ond ", "line\n", "tail"} {
+		n, err := l.Write([]byte(frag))
+		isNil(err, t)
+		equals(len(frag), n, t)
+	}
+
+	// the second line did not fit after the first, so we rotated
+	// between them rather than in the middle of the second.
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	equals(1, len(files), t)
+	data, err := ioutil.ReadFile(filepath.Join(adir, files[0].Name()))
+	isNil(err, t)
+	equals("first line\n", string(data), t)
+
+	// the incomplete tail is still buffered.
+	data, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("second line\n", string(data), t)
+
+	// Close flushes it.
+	isNil(l.Close(), t)
+	files, err = l.oldLogFiles(false)
+	isNil(err, t)
+	equals(2, len(files), t)
+	data, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("tail", string(data), t)
+}
+
+func TestLineAtomicMaxPending(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestLineAtomicMaxPending", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:        filename,
+		LineAtomic:      true,
+		MaxPendingBytes: 4,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("abc"))
+	isNil(err, t)
+	notExist(filename, t)
+
+	_, err = l.Write([]byte("de"))
+	isNil(err, t)
+	existsWithLen(filename, 5, t)
+}
+
+func TestLineAtomicRejectOversizeLine(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+
+	tmp := makeTempDir("TestLineAtomicRejectOversizeLine", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 10,
+		LineAtomic:   true,
+	}
+	defer l.Close()
+
+	// the oversize line is rejected, once, and dropped; the line
+	// before it is written and the whole of p is taken.
+	p := "ok\n" + strings.Repeat("x", 20) + "\n"
+	n, err := l.Write([]byte(p))
+	notNil(err, t)
+	equals(len(p), n, t)
+
+	for i := 0; i < 3; i++ {
+		n, err = l.Write([]byte("next\n"))
+		isNil(err, t)
+		equals(5, n, t)
+	}
+	isNil(l.Close(), t)
+
+	var all string
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := ioutil.ReadFile(filepath.Join(l.archiveDir(), files[i].Name()))
+		isNil(err, t)
+		all += string(data)
+	}
+	data, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	all += string(data)
+	equals("ok\nnext\nnext\nnext\n", all, t)
+}
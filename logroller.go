@@ -1,5 +1,5 @@
 // Package logroller descends from Nate Finch's lumberjack.v2
-// and provides a similar rolling logger, with three additions:
+// and provides a similar rolling logger. Its additions include:
 //
 // 1) gzip compression of logs, from https://github.com/natefinch/lumberjack/pull/16 and donovansolms:v2.0
 //
@@ -9,6 +9,10 @@
 //    log to every subsequent rotated log, in order to capture
 //    version, config info, and command line args.
 //
+// 4) control over writes larger than a whole file, with OversizeWrites.
+//
+// The README lists the rest.
+//
 //
 //   import "github.com/glycerine/logroller"
 //
@@ -27,15 +31,21 @@
 package logroller
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -123,12 +133,232 @@ type Logger struct {
 
 	// Preamble records the first N logged lines for replay at
 	// the top of every new log file, where N is PreambleLineCount.
+	// Each entry is one newline-terminated line, regardless of
+	// how the lines were split across calls to Write.
 	Preamble []string
 
+	// PersistPreamble saves the Preamble to a sidecar file next to
+	// Filename (Filename + ".preamble") and reloads it when the
+	// Logger first opens its file, so that a restarted process
+	// keeps replaying the original banner. The file is written once
+	// the Preamble is complete, or else on rotation and Close, and
+	// a failure to write it is reported by the next Write or Close.
+	PersistPreamble bool `json:"persistpreamble,omitempty" yaml:"persistpreamble,omitempty"`
+
+	// ReplacePreamble, together with PersistPreamble, discards the
+	// persisted Preamble on open, so that this process captures a
+	// new one and overwrites the sidecar file with it.
+	ReplacePreamble bool `json:"replacepreamble,omitempty" yaml:"replacepreamble,omitempty"`
+
+	// Header, if set, is called each time a new log file is
+	// opened, and the bytes it returns are written at the top of
+	// the file right after the replayed Preamble. Use it for
+	// context that should be fresh in every file, such as the
+	// hostname, build SHA, or the name of the previous file.
+	Header func(info RotationInfo) []byte `json:"-" yaml:"-"`
+
+	// EndOfPreambleMarker is the line written after the Preamble
+	// and Header, when there are any. It defaults to
+	// "___***___END_OF_PREAMBLE___***___".
+	EndOfPreambleMarker string `json:"endofpreamblemarker,omitempty" yaml:"endofpreamblemarker,omitempty"`
+
+	// Footer, when set, makes every rotation finish the outgoing
+	// file with a trailer line that records the rotation time and
+	// reason, the bytes and lines in the file, and the base name it
+	// is being archived under. Each new file then starts with a line
+	// naming the archive before it, so the chain of files can be
+	// followed after they are copied away.
+	Footer bool `json:"footer,omitempty" yaml:"footer,omitempty"`
+
+	// PreambleMatch, if set, selects which lines are captured into
+	// the Preamble: only lines for which it returns true are kept,
+	// and the rest are skipped without counting against
+	// PreambleLineCount. With a matcher, a PreambleLineCount of
+	// zero means no line limit.
+	PreambleMatch func(line []byte) bool `json:"-" yaml:"-"`
+
+	// PreamblePattern is a regular expression used to select
+	// Preamble lines, as for PreambleMatch, when PreambleMatch
+	// is not set. For example `\[banner\]`.
+	PreamblePattern string `json:"preamblepattern,omitempty" yaml:"preamblepattern,omitempty"`
+
+	// PreambleMaxBytes caps the total size of the captured
+	// Preamble. Capture stops at the first line that would go
+	// over. When a matcher is set and PreambleLineCount is zero,
+	// it defaults to 64 kilobytes; otherwise there is no cap.
+	PreambleMaxBytes int `json:"preamblemaxbytes,omitempty" yaml:"preamblemaxbytes,omitempty"`
+
+	// KeepManifest maintains a JSON manifest in the archive
+	// directory that records, for each rotated file, its name,
+	// first and last write times, byte and line counts,
+	// compression, and SHA-256 checksum. It is updated on
+	// rotation, compression and deletion, and can answer time
+	// range queries without opening the archives.
+	KeepManifest bool `json:"keepmanifest,omitempty" yaml:"keepmanifest,omitempty"`
+
+	// Checksums writes a sha256sum-style sidecar file,
+	// archive + ".sha256", next to each rotated file, and
+	// replaces it when the archive is compressed. Verify checks
+	// the archives against them.
+	Checksums bool `json:"checksums,omitempty" yaml:"checksums,omitempty"`
+
+	// HashChain makes each new log file start with a link line
+	// that records the name and SHA-256 of the previous file's
+	// contents, so that the files form a tamper-evident chain
+	// from the first log onward. VerifyChain walks the chain.
+	HashChain bool `json:"hashchain,omitempty" yaml:"hashchain,omitempty"`
+
+	// SigningKey, if set along with HashChain, is used to add an
+	// ed25519 signature over the previous file's hash to each
+	// link.
+	SigningKey ed25519.PrivateKey `json:"-" yaml:"-"`
+
+	// Encryption, if set, encrypts rotated log files with
+	// AES-256-GCM, after any compression, using keys from the
+	// provider. Encrypted archives get a ".enc" extension and
+	// are decrypted on the fly by NewReader, Search, Follow and
+	// VerifyChain, which need the same provider to read them.
+	Encryption KeyProvider `json:"-" yaml:"-"`
+
+	// OversizeWrites decides what happens to a single Write that
+	// is larger than MaxSizeBytes. The default, OversizeReject,
+	// returns an error and writes nothing.
+	OversizeWrites OversizePolicy `json:"oversizewrites,omitempty" yaml:"oversizewrites,omitempty"`
+
+	// LineAtomic buffers any incomplete trailing line until its
+	// newline arrives, so that rotation only ever happens between
+	// whole lines. Buffered bytes are flushed on Close, or early
+	// if they exceed MaxPendingBytes.
+	LineAtomic bool `json:"lineatomic,omitempty" yaml:"lineatomic,omitempty"`
+
+	// MaxPendingBytes bounds how much of an incomplete line
+	// LineAtomic mode will hold before writing it out anyway.
+	// It defaults to 64 kilobytes.
+	MaxPendingBytes int `json:"maxpendingbytes,omitempty" yaml:"maxpendingbytes,omitempty"`
+
+	// JSONLines requires every line written to be a JSON object.
+	// It implies LineAtomic. Lines that are not are diverted to
+	// the QuarantineFile instead of the log. An incomplete line
+	// flushed early or on Close is checked like any other, as if
+	// its newline had arrived.
+	JSONLines bool `json:"jsonlines,omitempty" yaml:"jsonlines,omitempty"`
+
+	// JSONTimestamp, JSONHost and JSONSeq add "ts", "host" and
+	// "seq" fields to each line in JSONLines mode: the time of
+	// the write in RFC 3339 format, os.Hostname, and a count of
+	// the lines accepted since the Logger was created, from 1.
+	// A field the line already has is left alone.
+	JSONTimestamp bool `json:"jsontimestamp,omitempty" yaml:"jsontimestamp,omitempty"`
+	JSONHost      bool `json:"jsonhost,omitempty" yaml:"jsonhost,omitempty"`
+	JSONSeq       bool `json:"jsonseq,omitempty" yaml:"jsonseq,omitempty"`
+
+	// QuarantineFile receives the malformed lines in JSONLines
+	// mode. It is appended to and never rotated. It defaults to
+	// the log filename with ".quarantine" appended.
+	QuarantineFile string `json:"quarantinefile,omitempty" yaml:"quarantinefile,omitempty"`
+
+	// LineTimeFormat, if set, prefixes each line with the time of
+	// the write, formatted with this time.Format layout, and a
+	// space. It implies LineAtomic.
+	LineTimeFormat string `json:"linetimeformat,omitempty" yaml:"linetimeformat,omitempty"`
+
+	// LineSeq prefixes each line with a sequence number and a
+	// space, ahead of any LineTimeFormat timestamp. The sequence
+	// continues across rotations and, through a sidecar file next
+	// to Filename (Filename + ".seq") and the numbered lines in the
+	// current file, across restarts. It implies LineAtomic. Neither
+	// prefix is added in JSONLines mode, which has JSONTimestamp and
+	// JSONSeq instead.
+	LineSeq bool `json:"lineseq,omitempty" yaml:"lineseq,omitempty"`
+
+	// Redactors scrub secrets from each line before it is
+	// written, in order, and from the Preamble. They imply
+	// LineAtomic, so a secret split across two Writes is still
+	// seen whole. See BuiltinRedactors and RedactionStats.
+	Redactors []Redactor `json:"-" yaml:"-"`
+
+	// DedupeWindow, if set, collapses a line repeated within this
+	// long of its first appearance into that first line, followed
+	// by "last message repeated N times" once a different line
+	// arrives, the window ends, the file rotates, or the Logger is
+	// closed. Lines are compared after the Redactors run. It
+	// implies LineAtomic.
+	DedupeWindow time.Duration `json:"dedupewindow,omitempty" yaml:"dedupewindow,omitempty"`
+
+	// RateLimit, if set, caps the rate at which lines are
+	// written. It implies LineAtomic.
+	RateLimit *RateLimit `json:"ratelimit,omitempty" yaml:"ratelimit,omitempty"`
+
+	// preambleInit is set once PreamblePattern has been compiled
+	// and any persisted Preamble loaded.
+	preambleInit bool
+	preambleRe   *regexp.Regexp
+	// preambleFull is set once PreambleMaxBytes has been reached.
+	preambleFull bool
+
+	// preamblePartial holds the start of a preamble line whose
+	// newline has not been written yet.
+	preamblePartial []byte
+	// preambleDirty is set when the Preamble has changed since it
+	// was last persisted, and preambleErr holds the error from a
+	// failed save until it is reported.
+	preambleDirty bool
+	preambleErr   error
+
+	// pending holds the incomplete trailing line in LineAtomic mode.
+	pending []byte
+
+	// limiter is the RateLimit's state.
+	limiter limiter
+
+	// lastLine is the last line DedupeWindow let through, at
+	// lastLineTime, and repeats counts the copies of it since.
+	lastLine     []byte
+	lastLineTime time.Time
+	repeats      int64
+
+	// redactions counts the secrets replaced by each Redactor.
+	redactions map[string]int64
+
+	// lineSeq is the last LineSeq number written, once
+	// lineSeqInit has recovered it.
+	lineSeq     int64
+	lineSeqInit bool
+
+	// jsonSeq counts the lines accepted in JSONLines mode.
+	jsonSeq int64
+	// hostname is os.Hostname, once looked up for JSONHost.
+	hostname string
+	// quarantine is the open QuarantineFile.
+	quarantine *os.File
+
 	size int64
+	// lines counts the newlines in the current file, for the Footer.
+	lines int64
+	// hash is the running SHA-256 of the current file, when
+	// hashing() is on.
+	hash hash.Hash
+	// firstWrite and lastWrite bracket the writes to the current
+	// file, for the manifest.
+	firstWrite time.Time
+	lastWrite  time.Time
+	// hdrSize is the number of bytes that openNew wrote at the
+	// top of the current file (the replayed Preamble), so we
+	// can tell whether the file holds any fresh writes yet.
+	hdrSize int64
+
+	// redirectStdio is set by RedirectStdio, to point the process's
+	// stdout and stderr at each new file as it is opened.
+	redirectStdio bool
+
 	file *os.File
 	mu   sync.Mutex
 	cmu  sync.Mutex
+	// processQueued is set while a compressLogs goroutine has been
+	// started but has not yet listed the archives.
+	processQueued int32
+	// mmu serializes updates to the manifest.
+	mmu sync.Mutex
 }
 
 const Megabyte = 1024 * 1024
@@ -144,49 +374,134 @@ var (
 // Write implements io.Writer.  If a write would cause the log file to be larger
 // than MaxSize, the file is closed, renamed to include a timestamp of the
 // current time, and a new log file is created using the original log file name.
-// If the length of the write is greater than MaxSize, an error is returned.
+// If the length of the write is greater than MaxSize, what happens
+// depends on OversizeWrites; by default an error is returned.
 func (l *Logger) Write(p []byte) (n int, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.lineMode() {
+		n, err = l.writeLineAtomic(p)
+	} else {
+		n, err = l.emit(p)
+	}
+	if err == nil {
+		err = l.takePreambleErr()
+	}
+	return n, err
+}
+
+// emit applies the OversizeWrites policy to p and writes it.
+func (l *Logger) emit(p []byte) (n int, err error) {
 	writeLen := int64(len(p))
 	if writeLen > l.max() {
-		return 0, fmt.Errorf(
-			"write length %d exceeds maximum file size %d", writeLen, l.max(),
-		)
+		switch l.OversizeWrites {
+		case OversizeSplit:
+			return l.writeSplit(p)
+		case OversizeTruncate:
+			return l.writeTruncated(p)
+		default:
+			return 0, fmt.Errorf(
+				"write length %d exceeds maximum file size %d", writeLen, l.max(),
+			)
+		}
 	}
 
+	return l.write(p)
+}
+
+// write opens the log file if need be, rotates if p would not fit,
+// and then writes p to the file, capturing it into the Preamble
+// if we are still within the first PreambleLineCount lines.
+func (l *Logger) write(p []byte) (n int, err error) {
+	writeLen := int64(len(p))
+
 	if l.file == nil {
 		if err = l.openExistingOrNew(len(p)); err != nil {
 			return 0, err
 		}
-		if l.CompressBackups {
-			go l.compressLogs(false)
-		}
+		l.startProcessing()
 	}
 
 	if l.size+writeLen > l.max() {
-		if err := l.rotate(); err != nil {
+		if err := l.rotate(RotateSize); err != nil {
 			return 0, err
 		}
 	}
 
-	n, err = l.file.Write(p)
-	l.size += int64(n)
+	n, err = l.writeFile(p)
 	//fmt.Printf("Write wrote %v '%s' to file %s\n", n, string(p), l.file.Name())
 
-	if len(l.Preamble) < l.PreambleLineCount {
-		l.Preamble = append(l.Preamble, string(p))
+	// filtered lines are captured by emitLine, as they came in.
+	if !l.filtering() {
+		l.capturePreamble(p[:n])
 	}
 
 	return n, err
 }
 
+// writeFile writes b to the current file, keeping the size, line
+// count, running checksum and write times of the file up to date.
+func (l *Logger) writeFile(b []byte) (int, error) {
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	l.lines += int64(bytes.Count(b[:n], newline))
+	if l.hash != nil {
+		l.hash.Write(b[:n])
+	}
+	if l.KeepManifest && n > 0 {
+		now := currentTime()
+		if l.firstWrite.IsZero() {
+			l.firstWrite = now
+		}
+		l.lastWrite = now
+	}
+	return n, err
+}
+
+// resetFileStats starts the bookkeeping for a newly opened file.
+func (l *Logger) resetFileStats() {
+	l.size = 0
+	l.lines = 0
+	l.hdrSize = 0
+	l.hash = nil
+	// writes made straight to a redirected stdout or stderr miss a
+	// running hash, so then recordArchive reads the file instead.
+	if l.hashing() && !l.redirectStdio {
+		l.hash = sha256.New()
+	}
+	l.firstWrite = time.Time{}
+	l.lastWrite = time.Time{}
+}
+
+// hashing reports whether we keep a running checksum of the
+// current file.
+func (l *Logger) hashing() bool {
+	return l.KeepManifest || l.Checksums || l.HashChain
+}
+
 // Close implements io.Closer, and closes the current logfile.
 func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.close()
+	err := l.flushPending()
+	if err == nil {
+		err = l.writeSummaries(true)
+	}
+	if serr := l.saveLineSeq(); err == nil {
+		err = serr
+	}
+	l.flushPreamble()
+	if perr := l.takePreambleErr(); err == nil {
+		err = perr
+	}
+	if qerr := l.closeQuarantine(); err == nil {
+		err = qerr
+	}
+	if cerr := l.close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // close closes the file if it is open.
@@ -207,31 +522,61 @@ func (l *Logger) close() error {
 func (l *Logger) Rotate() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.rotate()
+	return l.rotate(RotateManual)
+}
+
+// RotateWithReason is like Rotate, but records the given reason
+// in the RotationInfo passed to Header and in the Footer, for
+// example RotateSignal when rotating in response to SIGHUP.
+func (l *Logger) RotateWithReason(reason RotationReason) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rotate(reason)
 }
 
 // rotate closes the current file, moves it aside with a timestamp in the name,
 // (if it exists), opens a new file with the original filename, and then runs
-// cleanup.
-func (l *Logger) rotate() error {
+// cleanup. The reason is passed along to the Footer and Header.
+func (l *Logger) rotate(reason RotationReason) error {
 	//fmt.Printf("rotate() happening\n")
+	info := l.newRotationInfo(reason)
+	if l.file != nil {
+		// the counts of dropped lines belong with the lines they
+		// count.
+		if err := l.writeSummaries(true); err != nil {
+			return err
+		}
+	}
+	// a repeat in the new file is written out in full, so that its
+	// count has a line in the same file to refer to.
+	l.lastLine = nil
+	if err := l.writeFooter(info); err != nil {
+		return err
+	}
+	if err := l.saveLineSeq(); err != nil {
+		return err
+	}
+	l.flushPreamble()
 	if err := l.close(); err != nil {
 		return err
 	}
 
-	if err := l.openNew(); err != nil {
+	if err := l.openNew(info); err != nil {
 		return err
 	}
 	return l.cleanup()
 }
 
 // openNew opens a new log file for writing, moving any old log file out of the
-// way.  This methods assumes the file has already been closed.
-func (l *Logger) openNew() error {
+// way to info.PreviousFile.  This methods assumes the file has already been closed.
+func (l *Logger) openNew(info RotationInfo) error {
 	err := os.MkdirAll(l.currentLogDir(), 0744)
 	if err != nil {
 		return fmt.Errorf("can't make directory for new logfile: %s", err)
 	}
+	if err := l.initPreamble(); err != nil {
+		return err
+	}
 	err = os.MkdirAll(l.archiveDir(), 0744)
 	if err != nil {
 		return fmt.Errorf("can't make directory for rotated logfiles: %s", err)
@@ -239,21 +584,30 @@ func (l *Logger) openNew() error {
 	name := l.filename()
 
 	mode := os.FileMode(0644)
-	info, err := os_Stat(name)
+	finfo, err := os_Stat(name)
 	if err == nil {
 		// Copy the mode off the old logfile.
-		mode = info.Mode()
-		// move the existing file
-		newname := backupName(name, l.archiveDir(), l.LocalTime)
+		mode = finfo.Mode()
+		// move the existing file, recording it first, so that a
+		// compressLogs goroutine that finds it in the archive
+		// directory also finds the records it must update.
+		newname := info.PreviousFile
+		sum, err := l.recordArchive(name, newname, info.Time)
+		if err != nil {
+			return err
+		}
+		info.PreviousSHA256 = sum
 		if err := os.Rename(name, newname); err != nil {
 			return fmt.Errorf("can't rename log file: %s", err)
 		}
 		//fmt.Printf("openNew has renamed %s -> %s\n", name, newname)
 
 		// this is a no-op anywhere but linux
-		if err := chown(name, info); err != nil {
+		if err := chown(name, finfo); err != nil {
 			return err
 		}
+	} else {
+		info.PreviousFile = ""
 	}
 
 	// we use truncate here because this should only get called when we've moved
@@ -264,34 +618,28 @@ func (l *Logger) openNew() error {
 		return fmt.Errorf("can't open new logfile: %s", err)
 	}
 	l.file = f
-	l.size = 0
+	l.resetFileStats()
+	if l.redirectStdio {
+		if err := dupStdio(f); err != nil {
+			return err
+		}
+	}
 
 	// replay the Preamble, so that the original version/config
 	// lines (the first l.PreambleLineCount lines logged) are retained at
-	// the top of each log file.
-	if len(l.Preamble) > 0 {
-		for i := range l.Preamble {
-			writeme := []byte(l.Preamble[i])
-			n, err := l.file.Write(writeme)
-			l.size += int64(n)
-			if err != nil {
-				return err
-			}
-		}
-		n, err := l.file.WriteString("___***___END_OF_PREAMBLE___***___\n")
-		l.size += int64(n)
-		if err != nil {
-			return err
-		}
+	// the top of each log file, followed by any dynamic Header.
+	if err := l.writeHeader(info); err != nil {
+		return err
 	}
+	l.hdrSize = l.size
 
 	return nil
 }
 
-// backupName creates a new filename from the given name, inserting a timestamp
-// between the filename and the extension, using the local time if requested
+// backupName creates a new filename from the given name, inserting the timestamp
+// t between the filename and the extension, using the local time if requested
 // (otherwise UTC).
-func backupName(name, archiveDir string, local bool) string {
+func backupName(name, archiveDir string, t time.Time, local bool) string {
 	dir := filepath.Dir(name)
 	if len(archiveDir) > 0 {
 		dir = archiveDir
@@ -299,7 +647,6 @@ func backupName(name, archiveDir string, local bool) string {
 	filename := filepath.Base(name)
 	ext := filepath.Ext(filename)
 	prefix := filename[:len(filename)-len(ext)]
-	t := currentTime()
 	if !local {
 		t = t.UTC()
 	}
@@ -312,27 +659,47 @@ func backupName(name, archiveDir string, local bool) string {
 // would not put it over MaxSize.  If there is no such file or the write would
 // put it over the MaxSize, a new file is created.
 func (l *Logger) openExistingOrNew(writeLen int) error {
+	if err := l.initPreamble(); err != nil {
+		return err
+	}
+	// we don't have the file open, so any stats we kept from an
+	// earlier open can't be trusted for it.
+	l.hash = nil
+
 	filename := l.filename()
 	info, err := os_Stat(filename)
 	if os.IsNotExist(err) {
-		return l.openNew()
+		return l.openNew(l.newRotationInfo(RotateStartup))
 	}
 	if err != nil {
 		return fmt.Errorf("error getting log file info: %s", err)
 	}
 
 	if info.Size()+int64(writeLen) >= l.max() {
-		return l.rotate()
+		return l.rotate(RotateSize)
 	}
 
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		// if we fail to open the old log file for some reason, just ignore
 		// it and open a new log file.
-		return l.openNew()
+		return l.openNew(l.newRotationInfo(RotateStartup))
 	}
 	l.file = file
+	l.resetFileStats()
 	l.size = info.Size()
+	if l.redirectStdio {
+		if err := dupStdio(file); err != nil {
+			return err
+		}
+	}
+	if l.Footer || l.hash != nil {
+		if err := l.scanExisting(filename); err != nil {
+			// a hash of part of the file is wrong; without one,
+			// recordArchive reads the whole file at rotation.
+			l.hash = nil
+		}
+	}
 
 	return nil
 }
@@ -349,19 +716,30 @@ func (l *Logger) filename() string {
 // cleanup deletes old log files, keeping at most l.MaxBackups files, as long as
 // none of them are older than MaxAge.
 func (l *Logger) cleanup() error {
-	if l.CompressBackups {
-		go l.compressLogs(false)
-	}
+	l.startProcessing()
 
 	if l.MaxBackups == 0 && l.MaxAge == 0 {
 		return nil
 	}
 
-	files, err := l.oldLogFiles(l.CompressBackups)
+	files, err := l.archivesWithSuffix(l.storedSuffix())
 	if err != nil {
 		return err
 	}
 
+	deletes := l.expired(files)
+	if len(deletes) == 0 {
+		return nil
+	}
+
+	go l.deleteArchives(deletes)
+
+	return nil
+}
+
+// expired returns those of files, sorted newest first, that
+// MaxBackups and MaxAge say should be deleted.
+func (l *Logger) expired(files []logInfo) []logInfo {
 	var deletes []logInfo
 
 	if l.MaxBackups > 0 && l.MaxBackups < len(files) {
@@ -379,14 +757,18 @@ func (l *Logger) cleanup() error {
 			}
 		}
 	}
+	return deletes
+}
 
-	if len(deletes) == 0 {
-		return nil
+// deleteArchives removes files from the archive directory and
+// from the manifest.
+func (l *Logger) deleteArchives(files []logInfo) {
+	deleteAll(l.archiveDir(), files)
+	if l.KeepManifest {
+		if err := l.forgetArchives(files); err != nil {
+			fmt.Fprintf(os.Stderr, "\nUnable to update manifest: %s\n", err)
+		}
 	}
-
-	go deleteAll(l.archiveDir(), deletes)
-
-	return nil
 }
 
 func deleteAll(dir string, files []logInfo) {
@@ -394,30 +776,108 @@ func deleteAll(dir string, files []logInfo) {
 	for _, f := range files {
 		// what am I going to do, log this?
 		_ = os.Remove(filepath.Join(dir, f.Name()))
+		_ = os.Remove(filepath.Join(dir, f.Name()+checksumExtension))
 	}
 }
 
-// compressLogs compresses any uncompressed logs during the cleanup process
+// compressLogs compresses and then encrypts, as configured, any
+// logs not yet processed during the cleanup process
 func (l *Logger) compressLogs(printErrToStderr bool) {
+	// with Encryption alone, we only encrypt.
+	compress := l.CompressBackups || l.Encryption == nil
+	l.processArchives(compress, func(err error) {
+		if printErrToStderr {
+			fmt.Fprintf(os.Stderr, "\n%s\n", err)
+		}
+	})
+}
+
+// processArchives compresses, if compress is set, and then encrypts,
+// if Encryption is set, every rotated log not yet processed, passing
+// any errors to report as it goes.
+func (l *Logger) processArchives(compress bool, report func(error)) {
 	l.cmu.Lock()
 	defer l.cmu.Unlock()
+	// files rotated from here on need another pass.
+	atomic.StoreInt32(&l.processQueued, 0)
 	files, err := l.oldLogFiles(false)
 	if err != nil {
-		if printErrToStderr {
-			fmt.Fprintf(os.Stderr, "\nUnable to read rotated log files: %s\n", err)
+		report(fmt.Errorf("Unable to read rotated log files: %s", err))
+	}
+	if l.Encryption != nil && compress {
+		// compressed, but not encrypted before we last stopped.
+		compressed, err := l.oldLogFiles(true)
+		if err == nil {
+			files = append(files, compressed...)
 		}
 	}
 
 	for _, file := range files {
-		_, ext := l.prefixAndExt()
-		if ext != compressFileExtension {
-			if err := compressLog(filepath.Join(l.archiveDir(), file.Name())); err != nil {
-				if printErrToStderr {
-					fmt.Fprintf(os.Stderr, "\nUnable to compress backup log file: %s\n", err)
-				}
+		name := file.Name()
+		if compress && !strings.HasSuffix(name, compressFileExtension) {
+			if err := compressLog(filepath.Join(l.archiveDir(), name)); err != nil {
+				report(fmt.Errorf("Unable to compress backup log file: %s", err))
+				continue
+			}
+			if err := l.recordStored(name, name+compressFileExtension); err != nil {
+				report(fmt.Errorf("Unable to record compressed log file: %s", err))
 			}
+			name += compressFileExtension
 		}
+		if l.Encryption != nil {
+			if err := encryptLog(filepath.Join(l.archiveDir(), name), l.Encryption); err != nil {
+				report(fmt.Errorf("Unable to encrypt backup log file: %s", err))
+				continue
+			}
+			if err := l.recordStored(name, name+encryptFileExtension); err != nil {
+				report(fmt.Errorf("Unable to record encrypted log file: %s", err))
+			}
+		}
+	}
+}
+
+// startProcessing starts a compressLogs goroutine for the files
+// rotated so far, unless one is already waiting to list them.
+func (l *Logger) startProcessing() {
+	if !l.processing() || !atomic.CompareAndSwapInt32(&l.processQueued, 0, 1) {
+		return
+	}
+	go l.compressLogs(false)
+}
+
+// processing reports whether rotated files are compressed or
+// encrypted after rotation.
+func (l *Logger) processing() bool {
+	return l.CompressBackups || l.Encryption != nil
+}
+
+// storedSuffix returns the extension that rotated files end up with
+// once compressLogs is done with them.
+func (l *Logger) storedSuffix() string {
+	var suffix string
+	if l.CompressBackups {
+		suffix += compressFileExtension
+	}
+	if l.Encryption != nil {
+		suffix += encryptFileExtension
 	}
+	return suffix
+}
+
+// archiveSuffixes lists every extension a rotated file may carry
+// on top of the log file's own, least processed first.
+var archiveSuffixes = []string{
+	"",
+	compressFileExtension,
+	encryptFileExtension,
+	compressFileExtension + encryptFileExtension,
+}
+
+// stripArchiveExt returns the name a rotated file had before it was
+// compressed or encrypted.
+func stripArchiveExt(name string) string {
+	name = strings.TrimSuffix(name, encryptFileExtension)
+	return strings.TrimSuffix(name, compressFileExtension)
 }
 
 // oldLogFiles returns the list of backup log files stored in the same
@@ -425,6 +885,15 @@ func (l *Logger) compressLogs(printErrToStderr bool) {
 // assumeCompressed to true will return files with the global
 // compressFileExtension.
 func (l *Logger) oldLogFiles(assumeCompressed bool) ([]logInfo, error) {
+	if assumeCompressed {
+		return l.archivesWithSuffix(compressFileExtension)
+	}
+	return l.archivesWithSuffix("")
+}
+
+// archivesWithSuffix is like oldLogFiles, but returns the backup log
+// files whose names end in the log file's extension plus suffix.
+func (l *Logger) archivesWithSuffix(suffix string) ([]logInfo, error) {
 	files, err := ioutil.ReadDir(l.archiveDir())
 	if err != nil {
 		return nil, fmt.Errorf("can't read log file directory: %s", err)
@@ -432,10 +901,7 @@ func (l *Logger) oldLogFiles(assumeCompressed bool) ([]logInfo, error) {
 	logFiles := []logInfo{}
 
 	prefix, ext := l.prefixAndExt()
-
-	if assumeCompressed {
-		ext = ext + compressFileExtension
-	}
+	ext += suffix
 
 	for _, f := range files {
 		if f.IsDir() {
@@ -779,6 +779,13 @@ func newFakeTime() {
 	fakeCurrentTime = fakeCurrentTime.Add(time.Hour * 24 * 2)
 }
 
+// tickingFakeTime advances the fake "current time" by a millisecond on
+// every call, so that back to back rotations get distinct backup names.
+func tickingFakeTime() time.Time {
+	fakeCurrentTime = fakeCurrentTime.Add(time.Millisecond)
+	return fakeCurrentTime
+}
+
 func notExist(path string, t testing.TB) {
 	_, err := os.Stat(path)
 	assertUp(os.IsNotExist(err), t, 1, "expected to get os.IsNotExist, but instead got %v", err)
@@ -0,0 +1,86 @@
+package logroller
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Archive describes one rotated log file in the archive directory.
+type Archive struct {
+	// Path is the full path of the file.
+	Path string
+
+	// Rotated is the time the file was rotated, as encoded in its
+	// name.
+	Rotated time.Time
+
+	// Size is the size of the file on disk.
+	Size int64
+}
+
+// Archives returns the rotated log files in the archive directory,
+// oldest first, compressed, encrypted or not. It reads only the
+// directory, so it may be used on logs written by another process.
+func (l *Logger) Archives() ([]Archive, error) {
+	files, err := l.archivedLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Archive, 0, len(files))
+	for i := len(files) - 1; i >= 0; i-- {
+		out = append(out, Archive{
+			Path:    filepath.Join(l.archiveDir(), files[i].Name()),
+			Rotated: files[i].timestamp,
+			Size:    files[i].Size(),
+		})
+	}
+	return out, nil
+}
+
+// Prune deletes the rotated log files that MaxBackups and MaxAge say
+// should go, as the Logger does after each rotation, but right away
+// and counting every archive, whatever its form. It returns the
+// paths it deleted, oldest first.
+func (l *Logger) Prune() ([]string, error) {
+	if l.MaxBackups == 0 && l.MaxAge == 0 {
+		return nil, nil
+	}
+	files, err := l.archivedLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	deletes := l.expired(files)
+	if len(deletes) == 0 {
+		return nil, nil
+	}
+
+	var deleted []string
+	for i := len(deletes) - 1; i >= 0; i-- {
+		name := filepath.Join(l.archiveDir(), deletes[i].Name())
+		if err := os.Remove(name); err != nil {
+			return deleted, err
+		}
+		os.Remove(name + checksumExtension)
+		deleted = append(deleted, name)
+	}
+	if l.KeepManifest {
+		return deleted, l.forgetArchives(deletes)
+	}
+	return deleted, nil
+}
+
+// Compress compresses, and then encrypts if Encryption is set, every
+// rotated log file not yet processed, as the Logger does in the
+// background after each rotation when CompressBackups is set. It
+// keeps going past a file it cannot process, and returns the first
+// error.
+func (l *Logger) Compress() error {
+	var first error
+	l.processArchives(true, func(err error) {
+		if first == nil {
+			first = err
+		}
+	})
+	return first
+}
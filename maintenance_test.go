@@ -0,0 +1,61 @@
+package logroller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestMaintenance", t)
+	defer os.RemoveAll(tmp)
+
+	w := &Logger{
+		Filename:     logFile(tmp),
+		MaxSizeBytes: 100,
+		KeepManifest: true,
+	}
+	var rotated []time.Time
+	for _, line := range []string{"a\n", "bb\n", "ccc\n", "dddd\n"} {
+		_, err := w.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		rotated = append(rotated, fakeTime())
+		isNil(w.Rotate(), t)
+	}
+	isNil(w.Close(), t)
+
+	// a fresh Logger, as the logroller command would use.
+	l := &Logger{Filename: w.Filename, KeepManifest: true}
+
+	as, err := l.Archives()
+	isNil(err, t)
+	equals(4, len(as), t)
+	for i, a := range as {
+		assert(a.Rotated.Equal(rotated[i]), t, "archive %d rotated at %v, expected %v", i, a.Rotated, rotated[i])
+		equals(int64(i+2), a.Size, t)
+	}
+
+	isNil(l.Compress(), t)
+	as, err = l.Archives()
+	isNil(err, t)
+	equals(4, len(as), t)
+	for _, a := range as {
+		assert(strings.HasSuffix(a.Path, compressFileExtension), t, "expected %s to be compressed", a.Path)
+	}
+
+	l.MaxBackups = 1
+	deleted, err := l.Prune()
+	isNil(err, t)
+	equals([]string{as[0].Path, as[1].Path, as[2].Path}, deleted, t)
+	fileCount(l.archiveDir(), 2, t) // one archive, and the manifest
+
+	m, err := l.ReadManifest()
+	isNil(err, t)
+	equals(1, len(m.Archives), t)
+	equals(filepath.Base(as[3].Path), m.Archives[0].Name, t)
+}
@@ -0,0 +1,260 @@
+package logroller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const manifestExtension = ".manifest.json"
+
+// Manifest is the index of rotated log files that a Logger keeps
+// in its archive directory when KeepManifest is set.
+type Manifest struct {
+	// Archives is sorted oldest first.
+	Archives []ArchiveEntry `json:"archives"`
+}
+
+// ArchiveEntry describes one rotated log file.
+type ArchiveEntry struct {
+	// Name is the base name of the archive in the archive
+	// directory, including any compression or encryption
+	// extension.
+	Name string `json:"name"`
+
+	// Rotated is the time the file was rotated, as encoded in
+	// its name.
+	Rotated time.Time `json:"rotated"`
+
+	// FirstWrite and LastWrite bracket the writes to the file.
+	// They are zero if unknown, as for a file left by an earlier
+	// process that this process never wrote to.
+	FirstWrite time.Time `json:"first_write,omitempty"`
+	LastWrite  time.Time `json:"last_write,omitempty"`
+
+	// Bytes and Lines count the uncompressed contents.
+	Bytes int64 `json:"bytes"`
+	Lines int64 `json:"lines"`
+
+	// Compression is "gzip" for a compressed archive, or empty.
+	Compression string `json:"compression,omitempty"`
+
+	// Encryption is "aes-256-gcm" for an encrypted archive, or
+	// empty.
+	Encryption string `json:"encryption,omitempty"`
+
+	// StoredBytes is the size of the archive on disk.
+	StoredBytes int64 `json:"stored_bytes"`
+
+	// SHA256 is the hex SHA-256 of the archive as stored.
+	SHA256 string `json:"sha256"`
+}
+
+// Between returns the archives that may hold writes made within
+// [from, to], oldest first. A zero from or to leaves that end of the
+// range open. An archive whose first write is unknown is taken to
+// start when the archive before it was rotated.
+func (m *Manifest) Between(from, to time.Time) []ArchiveEntry {
+	var out []ArchiveEntry
+	var prev time.Time
+	for _, e := range m.Archives {
+		start, end := e.FirstWrite, e.LastWrite
+		if start.IsZero() {
+			start = prev
+		}
+		if end.IsZero() {
+			end = e.Rotated
+		}
+		if overlaps(start, end, from, to) {
+			out = append(out, e)
+		}
+		prev = e.Rotated
+	}
+	return out
+}
+
+// ReadManifest returns the manifest from the archive directory. A
+// missing manifest reads as an empty one.
+func (l *Logger) ReadManifest() (*Manifest, error) {
+	l.mmu.Lock()
+	defer l.mmu.Unlock()
+	return l.readManifest()
+}
+
+// manifestFilename returns the name of the manifest file. It does
+// not share the backup prefix, so it is never mistaken for a backup.
+func (l *Logger) manifestFilename() string {
+	return filepath.Join(l.archiveDir(), filepath.Base(l.filename())+manifestExtension)
+}
+
+func (l *Logger) readManifest() (*Manifest, error) {
+	m := &Manifest{}
+	data, err := ioutil.ReadFile(l.manifestFilename())
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read manifest: %s", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("can't parse manifest: %s", err)
+	}
+	return m, nil
+}
+
+// updateManifest applies change to the manifest and atomically
+// replaces the manifest file with the result.
+func (l *Logger) updateManifest(change func(m *Manifest)) error {
+	l.mmu.Lock()
+	defer l.mmu.Unlock()
+
+	m, err := l.readManifest()
+	if err != nil {
+		return err
+	}
+	change(m)
+	sort.SliceStable(m.Archives, func(i, j int) bool {
+		return m.Archives[i].Rotated.Before(m.Archives[j].Rotated)
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := l.manifestFilename()
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("can't write manifest: %s", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("can't rename manifest: %s", err)
+	}
+	return nil
+}
+
+// recordArchive records the file current, about to be rotated to
+// archived, in the manifest and its checksum sidecar, as configured,
+// and returns its hex SHA-256, or empty if we are not hashing. If we
+// were writing that file, the stats we kept while writing are used;
+// otherwise the file is read to find them.
+func (l *Logger) recordArchive(current, archived string, rotated time.Time) (sum string, err error) {
+	if !l.hashing() {
+		return "", nil
+	}
+	e := ArchiveEntry{
+		Name:    filepath.Base(archived),
+		Rotated: rotated,
+	}
+	if l.hash != nil {
+		e.FirstWrite = l.firstWrite
+		e.LastWrite = l.lastWrite
+		e.Bytes = l.size
+		e.Lines = l.lines
+		e.StoredBytes = l.size
+		e.SHA256 = hex.EncodeToString(l.hash.Sum(nil))
+	} else {
+		sum, n, lines, err := hashFile(current)
+		if err != nil {
+			return "", fmt.Errorf("can't checksum rotated file: %s", err)
+		}
+		e.Bytes, e.StoredBytes, e.Lines, e.SHA256 = n, n, lines, sum
+	}
+
+	if l.Checksums {
+		if err := writeChecksum(archived, e.SHA256); err != nil {
+			return "", err
+		}
+	}
+	if l.KeepManifest {
+		err = l.updateManifest(func(m *Manifest) {
+			m.Archives = append(removeEntry(m.Archives, e.Name), e)
+		})
+	}
+	return e.SHA256, err
+}
+
+// recordStored updates the manifest entry and checksum sidecar for
+// the archive name after compressLog or encryptLog has replaced it
+// with stored.
+func (l *Logger) recordStored(name, stored string) error {
+	if !l.KeepManifest && !l.Checksums {
+		return nil
+	}
+	sum, n, _, err := hashFile(filepath.Join(l.archiveDir(), stored))
+	if err != nil {
+		return fmt.Errorf("can't checksum stored file: %s", err)
+	}
+
+	if l.Checksums {
+		if err := writeChecksum(filepath.Join(l.archiveDir(), stored), sum); err != nil {
+			return err
+		}
+		os.Remove(filepath.Join(l.archiveDir(), name+checksumExtension))
+	}
+	if !l.KeepManifest {
+		return nil
+	}
+	return l.updateManifest(func(m *Manifest) {
+		for i := range m.Archives {
+			e := &m.Archives[i]
+			if e.Name != name {
+				continue
+			}
+			e.Name = stored
+			if strings.HasSuffix(strings.TrimSuffix(stored, encryptFileExtension), compressFileExtension) {
+				e.Compression = "gzip"
+			}
+			if strings.HasSuffix(stored, encryptFileExtension) {
+				e.Encryption = "aes-256-gcm"
+			}
+			e.StoredBytes = n
+			e.SHA256 = sum
+		}
+	})
+}
+
+// forgetArchives removes deleted files from the manifest.
+func (l *Logger) forgetArchives(files []logInfo) error {
+	return l.updateManifest(func(m *Manifest) {
+		for _, f := range files {
+			m.Archives = removeEntry(m.Archives, f.Name())
+		}
+	})
+}
+
+// removeEntry drops the entry for name, in whatever form.
+func removeEntry(entries []ArchiveEntry, name string) []ArchiveEntry {
+	name = stripArchiveExt(name)
+	out := entries[:0]
+	for _, e := range entries {
+		if stripArchiveExt(e.Name) != name {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// hashFile returns the hex SHA-256, size and line count of the
+// named file.
+func hashFile(filename string) (sum string, n, lines int64, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err = io.Copy(io.MultiWriter(h, lineCounter{&lines}), f)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, lines, nil
+}
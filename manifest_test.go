@@ -0,0 +1,102 @@
+package logroller
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestManifest", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 100,
+		MaxBackups:   2,
+		KeepManifest: true,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	var rotated []time.Time
+	for _, line := range []string{"a\n", "b\nb\n", "c\nc\nc\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		rotated = append(rotated, fakeTime())
+		isNil(l.Rotate(), t)
+	}
+	// deletion happens on another goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	m, err := l.ReadManifest()
+	isNil(err, t)
+	equals(2, len(m.Archives), t)
+
+	e := m.Archives[0]
+	equals("foobar-"+rotated[1].UTC().Format(backupTimeFormat)+".log", e.Name, t)
+	equals(int64(4), e.Bytes, t)
+	equals(int64(2), e.Lines, t)
+	assert(rotated[0].Equal(e.FirstWrite), t, "first write %v, expected %v", e.FirstWrite, rotated[0])
+	sum, n, _, err := hashFile(filepath.Join(adir, e.Name))
+	isNil(err, t)
+	equals(sum, e.SHA256, t)
+	equals(n, e.StoredBytes, t)
+
+	l.compressLogs(false)
+	m, err = l.ReadManifest()
+	isNil(err, t)
+	equals(2, len(m.Archives), t)
+	e = m.Archives[1]
+	equals(filepath.Base(backupFileCompressed(adir)), e.Name, t)
+	equals("gzip", e.Compression, t)
+	equals(int64(6), e.Bytes, t)
+	sum, n, _, err = hashFile(filepath.Join(adir, e.Name))
+	isNil(err, t)
+	equals(sum, e.SHA256, t)
+	equals(n, e.StoredBytes, t)
+
+	// the most recent archive was last written at rotated[1].
+	equals(1, len(m.Between(rotated[1].Add(-time.Second), time.Time{})), t)
+	equals(2, len(m.Between(time.Time{}, time.Time{})), t)
+	equals(0, len(m.Between(rotated[1].Add(time.Second), time.Time{})), t)
+}
+
+func TestManifestRotateWhileCompressing(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+
+	tmp := makeTempDir("TestManifestRotateWhileCompressing", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:        logFile(tmp),
+		MaxSizeBytes:    20,
+		CompressBackups: true,
+		Checksums:       true,
+		KeepManifest:    true,
+	}
+	defer l.Close()
+
+	// compression of earlier archives runs while later ones are
+	// rotated in; each must end up recorded under its stored name.
+	for i := 0; i < 300; i++ {
+		_, err := l.Write([]byte("0123456789abcdef\n"))
+		isNil(err, t)
+	}
+	isNil(l.Close(), t)
+	isNil(l.Compress(), t)
+
+	r, err := l.Verify()
+	isNil(err, t)
+	assert(r.Intact(), t, "modified %v, missing %v, orphaned %v", r.Modified, r.Missing, r.Orphaned)
+	equals(299, len(r.OK), t)
+	m, err := l.ReadManifest()
+	isNil(err, t)
+	equals(299, len(m.Archives), t)
+}
@@ -0,0 +1,117 @@
+package logroller
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// OversizePolicy tells a Logger what to do with a single Write
+// whose length exceeds MaxSizeBytes.
+type OversizePolicy int
+
+const (
+	// OversizeReject returns an error and drops the whole write.
+	// This is the default, and matches lumberjack.
+	OversizeReject OversizePolicy = iota
+
+	// OversizeSplit spreads the write across as many consecutive
+	// log files as are needed, cutting at newline boundaries
+	// whenever a newline is available. Nothing is lost.
+	OversizeSplit
+
+	// OversizeTruncate keeps as much of the write as fits in one
+	// log file and replaces the rest with a marker line that
+	// records how many bytes were dropped.
+	OversizeTruncate
+)
+
+// truncatedMarker is appended in place of the bytes dropped
+// under OversizeTruncate.
+const truncatedMarker = "\n___***___LOGROLLER_TRUNCATED_%d_BYTES___***___\n"
+
+// writeSplit writes p, which is larger than l.max(), as a
+// sequence of chunks, rotating between them. Each chunk is cut
+// at the last newline that fits when there is one. If no newline
+// fits and the current file already holds fresh writes, we
+// rotate first so the chunk can start on a clean file.
+func (l *Logger) writeSplit(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if l.file == nil {
+			if err = l.openExistingOrNew(0); err != nil {
+				return n, err
+			}
+			l.startProcessing()
+		}
+
+		space := l.max() - l.size
+		if space <= 0 {
+			if err = l.rotate(RotateSize); err != nil {
+				return n, err
+			}
+			space = l.max() - l.size
+			if space <= 0 {
+				// the replayed preamble alone fills the file;
+				// make progress anyway.
+				space = l.max()
+			}
+		}
+
+		chunk := p
+		if int64(len(chunk)) > space {
+			chunk = chunk[:space]
+			if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+				chunk = chunk[:i+1]
+			} else if l.size > l.hdrSize {
+				if err = l.rotate(RotateSize); err != nil {
+					return n, err
+				}
+				continue
+			}
+		}
+
+		m, err := l.write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// writeTruncated writes p, which is larger than l.max(), cut short
+// to fit, with the truncation marker, in a file of its own after the
+// replayed Preamble and Header.
+func (l *Logger) writeTruncated(p []byte) (n int, err error) {
+	if l.file == nil {
+		if err = l.openExistingOrNew(len(p)); err != nil {
+			return 0, err
+		}
+		l.startProcessing()
+	}
+	if l.size > l.hdrSize {
+		if err = l.rotate(RotateSize); err != nil {
+			return 0, err
+		}
+	}
+	if _, err = l.write(l.truncateOversize(p, l.max()-l.size)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// truncateOversize returns the prefix of p that, together with
+// the truncation marker, fits within space bytes.
+func (l *Logger) truncateOversize(p []byte, space int64) []byte {
+	// the marker for len(p) dropped bytes is at least as long
+	// as the one we will actually write.
+	keep := space - int64(len(fmt.Sprintf(truncatedMarker, len(p))))
+	if keep < 0 {
+		keep = 0
+	}
+	marker := fmt.Sprintf(truncatedMarker, int64(len(p))-keep)
+
+	out := make([]byte, 0, keep+int64(len(marker)))
+	out = append(out, p[:keep]...)
+	return append(out, marker...)
+}
@@ -0,0 +1,122 @@
+package logroller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOversizeSplit(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+
+	tmp := makeTempDir("TestOversizeSplit", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:       filename,
+		MaxSizeBytes:   10,
+		OversizeWrites: OversizeSplit,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	b := []byte("line1\nline2\nline3\n")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	// each line was cut at its newline, so we get two archives
+	// and the last line in the current file.
+	fileCount(adir, 2, t)
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("line3\n", string(got), t)
+
+	// no newline at all: cut at exactly MaxSizeBytes.
+	b = []byte("abcdefghijklmnopqrstuvwxy")
+	n, err = l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	got, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("uvwxy", string(got), t)
+
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	var all []string
+	for i := len(files) - 1; i >= 0; i-- {
+		data, err := ioutil.ReadFile(filepath.Join(adir, files[i].Name()))
+		isNil(err, t)
+		all = append(all, string(data))
+	}
+	all = append(all, string(got))
+	equals("line1\nline2\nline3\nabcdefghijklmnopqrstuvwxy", strings.Join(all, ""), t)
+}
+
+func TestOversizeTruncate(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestOversizeTruncate", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:       filename,
+		MaxSizeBytes:   100,
+		OversizeWrites: OversizeTruncate,
+	}
+	defer l.Close()
+
+	b := []byte(strings.Repeat("x", 150))
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	assert(len(got) <= 100, t, "truncated write of %d bytes exceeds max", len(got))
+	kept := strings.Count(string(got), "x")
+	assert(strings.HasSuffix(string(got),
+		fmt.Sprintf(truncatedMarker, len(b)-kept)), t, "missing marker in %q", got)
+}
+
+func TestOversizeTruncatePreamble(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestOversizeTruncatePreamble", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:          filename,
+		MaxSizeBytes:      200,
+		OversizeWrites:    OversizeTruncate,
+		PreambleLineCount: 1,
+	}
+	defer l.Close()
+
+	preamble := "version 1.2.3 build abc\n"
+	_, err := l.Write([]byte(preamble))
+	isNil(err, t)
+
+	// the cut allows for the preamble replayed at the top of the
+	// file the truncated write goes to.
+	b := []byte(strings.Repeat("x", 300))
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	assert(len(got) <= 200, t, "truncated write left a file of %d bytes", len(got))
+	assert(strings.HasPrefix(string(got), preamble+endOfPreamble), t, "missing preamble in %q", got)
+	kept := strings.Count(string(got), "x")
+	assert(kept > 0, t, "nothing kept in %q", got)
+	assert(strings.HasSuffix(string(got),
+		fmt.Sprintf(truncatedMarker, len(b)-kept)), t, "missing marker in %q", got)
+}
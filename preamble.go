@@ -0,0 +1,290 @@
+package logroller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	endOfPreamble            = "___***___END_OF_PREAMBLE___***___\n"
+	preambleSidecarExtension = ".preamble"
+	defaultPreambleMaxBytes  = 64 * 1024
+)
+
+// capturePreamble adds the lines in p to the Preamble until it
+// is full. A line may arrive across any number of writes, and one
+// write may carry many lines; either way each Preamble entry is
+// exactly one newline-terminated line. Only lines selected by the
+// matcher, if there is one, are kept. With PersistPreamble, the
+// Preamble is saved once it is complete.
+func (l *Logger) capturePreamble(p []byte) {
+	if !l.capturingPreamble() {
+		return
+	}
+	defer func() {
+		if !l.capturingPreamble() {
+			l.flushPreamble()
+		}
+	}()
+	for len(p) > 0 && l.capturingPreamble() {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.preamblePartial = append(l.preamblePartial, p...)
+			return
+		}
+		line := append(l.preamblePartial, p[:i+1]...)
+		l.preamblePartial = line[:0]
+		p = p[i+1:]
+		if !l.preambleMatches(line) {
+			continue
+		}
+		if max := l.preambleMaxBytes(); max > 0 && l.preambleBytes()+len(line) > max {
+			l.preambleFull = true
+			return
+		}
+		l.Preamble = append(l.Preamble, string(line))
+		l.preambleDirty = true
+	}
+}
+
+// flushPreamble saves the Preamble, with PersistPreamble, if it has
+// changed since it was last saved. An error is kept for the next
+// Write or Close to report, since the write that completed the
+// Preamble has already succeeded.
+func (l *Logger) flushPreamble() {
+	if !l.PersistPreamble || !l.preambleDirty {
+		return
+	}
+	if err := l.savePreamble(); err != nil {
+		l.preambleErr = err
+	}
+}
+
+// takePreambleErr returns the error from the last failed save of
+// the Preamble, if it has not been reported yet, and forgets it.
+func (l *Logger) takePreambleErr() error {
+	err := l.preambleErr
+	l.preambleErr = nil
+	return err
+}
+
+// capturingPreamble reports whether we still want more lines
+// in the Preamble.
+func (l *Logger) capturingPreamble() bool {
+	if l.preambleFull {
+		return false
+	}
+	if l.PreambleLineCount > 0 {
+		return len(l.Preamble) < l.PreambleLineCount
+	}
+	return l.hasPreambleMatcher()
+}
+
+func (l *Logger) hasPreambleMatcher() bool {
+	return l.PreambleMatch != nil || l.preambleRe != nil
+}
+
+// preambleMatches reports whether line belongs in the Preamble.
+func (l *Logger) preambleMatches(line []byte) bool {
+	if l.PreambleMatch != nil {
+		return l.PreambleMatch(line)
+	}
+	if l.preambleRe != nil {
+		return l.preambleRe.Match(line)
+	}
+	return true
+}
+
+// preambleMaxBytes returns the cap on the Preamble size, or zero
+// for no cap.
+func (l *Logger) preambleMaxBytes() int {
+	if l.PreambleMaxBytes > 0 {
+		return l.PreambleMaxBytes
+	}
+	if l.PreambleLineCount == 0 && l.hasPreambleMatcher() {
+		return defaultPreambleMaxBytes
+	}
+	return 0
+}
+
+// preambleBytes returns the current size of the Preamble.
+func (l *Logger) preambleBytes() (n int) {
+	for _, line := range l.Preamble {
+		n += len(line)
+	}
+	return n
+}
+
+// SetPreamble replaces the Preamble with the given lines, for
+// programs that want to manage it directly rather than have it
+// captured from their first writes. Each string may hold several
+// lines. Automatic capture stops, and the new Preamble is
+// persisted if PersistPreamble is set. It is replayed at the top
+// of the next log file.
+func (l *Logger) SetPreamble(lines ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.initPreamble(); err != nil {
+		return err
+	}
+	l.Preamble = nil
+	return l.appendPreamble(lines)
+}
+
+// AppendPreamble adds the given lines to the end of the Preamble.
+// Like SetPreamble, it stops automatic capture.
+func (l *Logger) AppendPreamble(lines ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.initPreamble(); err != nil {
+		return err
+	}
+	return l.appendPreamble(lines)
+}
+
+func (l *Logger) appendPreamble(lines []string) error {
+	for _, s := range lines {
+		for _, line := range strings.SplitAfter(s, "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			l.Preamble = append(l.Preamble, string(l.redact([]byte(line))))
+		}
+	}
+	l.preamblePartial = l.preamblePartial[:0]
+	l.preambleFull = true
+	l.preambleDirty = true
+	if l.PersistPreamble {
+		return l.savePreamble()
+	}
+	return nil
+}
+
+// writeHeader starts the freshly opened file with the HashChain
+// link and the Footer's link to the previous file, when enabled,
+// then replays the captured Preamble, then writes the output of
+// the Header hook and the end of preamble marker. A partially
+// captured line is replayed too, and every line is
+// newline-terminated so the marker always stands on its own line.
+// Nothing at all is written when there are no such lines.
+func (l *Logger) writeHeader(info RotationInfo) error {
+	lines := l.Preamble
+	if link := l.previousLink(info); link != "" {
+		lines = append([]string{link}, lines...)
+	}
+	if l.HashChain {
+		lines = append([]string{l.chainLink(info)}, lines...)
+	}
+	if len(l.preamblePartial) > 0 && l.capturingPreamble() &&
+		l.preambleMatches(l.preamblePartial) {
+		lines = append(lines[:len(lines):len(lines)], string(l.preamblePartial))
+	}
+	if l.Header != nil {
+		if hdr := l.Header(info); len(hdr) > 0 {
+			lines = append(lines[:len(lines):len(lines)], string(hdr))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteString(l.endOfPreambleMarker())
+
+	_, err := l.writeFile(buf.Bytes())
+	return err
+}
+
+// endOfPreambleMarker returns the newline-terminated line that
+// ends the Preamble and Header.
+func (l *Logger) endOfPreambleMarker() string {
+	if l.EndOfPreambleMarker == "" {
+		return endOfPreamble
+	}
+	return strings.TrimSuffix(l.EndOfPreambleMarker, "\n") + "\n"
+}
+
+// preambleFilename returns the name of the sidecar file that
+// holds the persisted Preamble.
+func (l *Logger) preambleFilename() string {
+	return l.filename() + preambleSidecarExtension
+}
+
+// initPreamble compiles PreamblePattern and loads any persisted
+// Preamble, once, before the first file is opened.
+func (l *Logger) initPreamble() error {
+	if l.preambleInit {
+		return nil
+	}
+	if l.PreamblePattern != "" && l.PreambleMatch == nil {
+		re, err := regexp.Compile(l.PreamblePattern)
+		if err != nil {
+			return fmt.Errorf("bad PreamblePattern: %s", err)
+		}
+		l.preambleRe = re
+	}
+	if err := l.loadPreamble(); err != nil {
+		return err
+	}
+	l.Preamble = l.redactStrings(l.Preamble)
+	l.preambleInit = true
+	return nil
+}
+
+// loadPreamble reads the persisted Preamble from its sidecar
+// file if PersistPreamble is set and we have not already got a
+// Preamble in memory. A missing sidecar is not an error. A loaded
+// Preamble is taken as complete, so capture stops.
+func (l *Logger) loadPreamble() error {
+	if !l.PersistPreamble {
+		return nil
+	}
+	if l.ReplacePreamble || len(l.Preamble) > 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(l.preambleFilename())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't read preamble file: %s", err)
+	}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line != "" {
+			l.Preamble = append(l.Preamble, line)
+		}
+	}
+	// the persisted Preamble is complete; don't add this
+	// process's lines to it.
+	l.preambleFull = len(l.Preamble) > 0
+	return nil
+}
+
+// savePreamble atomically replaces the sidecar file with the
+// current Preamble.
+func (l *Logger) savePreamble() error {
+	name := l.preambleFilename()
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(l.Preamble, "")), 0644); err != nil {
+		return fmt.Errorf("can't write preamble file: %s", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("can't rename preamble file: %s", err)
+	}
+	l.preambleDirty = false
+	return nil
+}
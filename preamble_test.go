@@ -0,0 +1,320 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPreambleMultiLineWrite(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreambleMultiLineWrite", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 2,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("version 1\nconfig a\nnot preamble\n"))
+	isNil(err, t)
+	equals([]string{"version 1\n", "config a\n"}, l.Preamble, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("version 1\nconfig a\n"+endOfPreamble, string(got), t)
+}
+
+func TestPreambleFragmentedWrites(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreambleFragmentedWrites", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 3,
+	}
+	defer l.Close()
+
+	for _, frag := range []string{"ver", "sion 1", "\nconf", "ig a\nargs"} {
+		_, err := l.Write([]byte(frag))
+		isNil(err, t)
+	}
+	equals([]string{"version 1\n", "config a\n"}, l.Preamble, t)
+
+	// the unterminated third line is replayed on its own line,
+	// and the marker is not glued onto it.
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("version 1\nconfig a\nargs\n"+endOfPreamble, string(got), t)
+
+	_, err = l.Write([]byte(" -v\nlater\n"))
+	isNil(err, t)
+	equals([]string{"version 1\n", "config a\n", "args -v\n"}, l.Preamble, t)
+}
+
+func TestPreambleNoTrailingNewline(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreambleNoTrailingNewline", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename: filename,
+		Preamble: []string{"set by hand"},
+	}
+	defer l.Close()
+
+	isNil(l.Rotate(), t)
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("set by hand\n"+endOfPreamble, string(got), t)
+}
+
+func TestPersistPreamble(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPersistPreamble", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 1,
+		PersistPreamble:   true,
+	}
+	_, err := l.Write([]byte("version 1\nsomething else\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	got, err := ioutil.ReadFile(filename + preambleSidecarExtension)
+	isNil(err, t)
+	equals("version 1\n", string(got), t)
+
+	// a restarted process appends to the old file, and still
+	// replays the original banner on its next rotation.
+	l2 := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 1,
+		PersistPreamble:   true,
+	}
+	defer l2.Close()
+	_, err = l2.Write([]byte("after restart\n"))
+	isNil(err, t)
+	equals([]string{"version 1\n"}, l2.Preamble, t)
+
+	newFakeTime()
+	isNil(l2.Rotate(), t)
+	got, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("version 1\n"+endOfPreamble, string(got), t)
+}
+
+func TestReplacePreamble(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestReplacePreamble", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	err := ioutil.WriteFile(filename+preambleSidecarExtension, []byte("version 1\n"), 0644)
+	isNil(err, t)
+
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 1,
+		PersistPreamble:   true,
+		ReplacePreamble:   true,
+	}
+	defer l.Close()
+	_, err = l.Write([]byte("version 2\n"))
+	isNil(err, t)
+	equals([]string{"version 2\n"}, l.Preamble, t)
+
+	got, err := ioutil.ReadFile(filename + preambleSidecarExtension)
+	isNil(err, t)
+	equals("version 2\n", string(got), t)
+}
+
+func TestHeader(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestHeader", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	var infos []RotationInfo
+	l := &Logger{
+		Filename:            filename,
+		PreambleLineCount:   1,
+		EndOfPreambleMarker: "--- end of header ---",
+		Header: func(info RotationInfo) []byte {
+			infos = append(infos, info)
+			return []byte("reason=" + string(info.Reason))
+		},
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	_, err := l.Write([]byte("version 1\n"))
+	isNil(err, t)
+	got, err := ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("reason=startup\n--- end of header ---\nversion 1\n", string(got), t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	got, err = ioutil.ReadFile(filename)
+	isNil(err, t)
+	equals("version 1\nreason=manual\n--- end of header ---\n", string(got), t)
+
+	equals(2, len(infos), t)
+	equals("", infos[0].PreviousFile, t)
+	equals(filename, infos[1].Filename, t)
+	equals(backupFile(adir), infos[1].PreviousFile, t)
+	equals(fakeTime(), infos[1].Time, t)
+}
+
+func TestPreamblePattern(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreamblePattern", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:         filename,
+		PreamblePattern:  `\[banner\]`,
+		PreambleMaxBytes: 40,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("chatty\n[banner] version 1\nchatty\n[ban"))
+	isNil(err, t)
+	_, err = l.Write([]byte("ner] config a\n[banner] this line is too long to fit\n[banner] x\n"))
+	isNil(err, t)
+	equals([]string{"[banner] version 1\n", "[banner] config a\n"}, l.Preamble, t)
+}
+
+func TestPreamblePatternBad(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreamblePatternBad", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:        logFile(tmp),
+		PreamblePattern: `[`,
+	}
+	defer l.Close()
+	_, err := l.Write([]byte("boo!\n"))
+	notNil(err, t)
+}
+
+func TestPreambleMatchFunc(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPreambleMatchFunc", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:          logFile(tmp),
+		PreambleLineCount: 1,
+		PreambleMatch: func(line []byte) bool {
+			return len(line) > 0 && line[0] == '#'
+		},
+	}
+	defer l.Close()
+	_, err := l.Write([]byte("a\n# b\n# c\n"))
+	isNil(err, t)
+	equals([]string{"# b\n"}, l.Preamble, t)
+}
+
+func TestSetAndAppendPreamble(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestSetAndAppendPreamble", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 5,
+		PersistPreamble:   true,
+	}
+	defer l.Close()
+
+	isNil(l.SetPreamble("version 2\nconfig b"), t)
+	isNil(l.AppendPreamble("args -v\n"), t)
+	_, err := l.Write([]byte("not captured\n"))
+	isNil(err, t)
+	equals([]string{"version 2\n", "config b\n", "args -v\n"}, l.Preamble, t)
+
+	got, err := ioutil.ReadFile(filename + preambleSidecarExtension)
+	isNil(err, t)
+	equals("version 2\nconfig b\nargs -v\n", string(got), t)
+}
+
+func TestPersistPreambleOnceComplete(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPersistPreambleOnceComplete", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	sidecar := filename + preambleSidecarExtension
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 2,
+		PersistPreamble:   true,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("version 1\n"))
+	isNil(err, t)
+	notExist(sidecar, t)
+	_, err = l.Write([]byte("config x\n"))
+	isNil(err, t)
+	existsWithContent(sidecar, []byte("version 1\nconfig x\n"), t)
+}
+
+func TestPersistPreambleError(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestPersistPreambleError", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	// the sidecar can't be written through a directory in the way.
+	isNil(os.Mkdir(filename+preambleSidecarExtension+".tmp", 0755), t)
+	l := &Logger{
+		Filename:          filename,
+		PreambleLineCount: 1,
+		PersistPreamble:   true,
+	}
+	defer l.Close()
+
+	// the write itself went through, so all of it is reported.
+	b := []byte("version 1\nnext\n")
+	n, err := l.Write(b)
+	notNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, b, t)
+
+	// the error is reported once, and the save tried again on Close.
+	_, err = l.Write([]byte("more\n"))
+	isNil(err, t)
+	notNil(l.Close(), t)
+}
@@ -0,0 +1,116 @@
+package logroller
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimit configures a token bucket limiter on the lines a Logger
+// writes. Lines over the limit are dropped, or sampled, and a line
+// reporting how many lines and bytes were suppressed is written once
+// lines are let through again, on rotation, and on Close.
+type RateLimit struct {
+	// LinesPerSecond and BytesPerSecond are the sustained rates
+	// allowed. Zero leaves that measure unlimited.
+	LinesPerSecond float64 `json:"linespersecond,omitempty" yaml:"linespersecond,omitempty"`
+	BytesPerSecond float64 `json:"bytespersecond,omitempty" yaml:"bytespersecond,omitempty"`
+
+	// BurstLines and BurstBytes are how many lines and bytes may
+	// be written at once, after a quiet spell. They default to
+	// one second's worth. The burst is never less than one line,
+	// and a line bigger than BurstBytes may go when the bucket is
+	// full, so rates below one per second let a line through now
+	// and then rather than none at all.
+	BurstLines float64 `json:"burstlines,omitempty" yaml:"burstlines,omitempty"`
+	BurstBytes float64 `json:"burstbytes,omitempty" yaml:"burstbytes,omitempty"`
+
+	// SampleEvery, if set, lets every Nth line over the limit
+	// through anyway, so that a storm is still represented in the
+	// log.
+	SampleEvery int64 `json:"sampleevery,omitempty" yaml:"sampleevery,omitempty"`
+}
+
+// limiter holds the state of a Logger's RateLimit.
+type limiter struct {
+	init   bool
+	last   time.Time
+	lines  float64
+	bytes  float64
+	over   int64 // lines over the limit, for sampling
+	sLines int64 // lines suppressed since the last summary
+	sBytes int64
+}
+
+// limit reports whether line is within the RateLimit, or is let
+// through as a sample. A line that is not is counted as suppressed.
+func (l *Logger) limit(line []byte) bool {
+	rl, lm := l.RateLimit, &l.limiter
+	now := currentTime()
+	burstLines, burstBytes := rl.burst()
+	if !lm.init {
+		lm.init = true
+		lm.last = now
+		lm.lines, lm.bytes = burstLines, burstBytes
+	}
+	if elapsed := now.Sub(lm.last).Seconds(); elapsed > 0 {
+		lm.lines = minFloat(burstLines, lm.lines+elapsed*rl.LinesPerSecond)
+		lm.bytes = minFloat(burstBytes, lm.bytes+elapsed*rl.BytesPerSecond)
+		lm.last = now
+	}
+
+	n := float64(len(line))
+	// a line bigger than the whole bucket may go when it is full,
+	// leaving the bucket in debt.
+	if (rl.LinesPerSecond == 0 || lm.lines >= 1) &&
+		(rl.BytesPerSecond == 0 || lm.bytes >= minFloat(n, burstBytes)) {
+		lm.lines--
+		lm.bytes -= n
+		lm.over = 0
+		return true
+	}
+	lm.over++
+	if rl.SampleEvery > 0 && lm.over%rl.SampleEvery == 0 {
+		return true
+	}
+	lm.sLines++
+	lm.sBytes += int64(len(line))
+	return false
+}
+
+// burst returns the bucket sizes.
+func (rl *RateLimit) burst() (lines, bytes float64) {
+	lines, bytes = rl.BurstLines, rl.BurstBytes
+	if lines == 0 {
+		lines = rl.LinesPerSecond
+	}
+	if lines < 1 {
+		lines = 1
+	}
+	if bytes == 0 {
+		bytes = rl.BytesPerSecond
+	}
+	return lines, bytes
+}
+
+// takeSuppressed returns the line reporting the lines suppressed so
+// far, or nil if there are none, and resets the count.
+func (l *Logger) takeSuppressed() []byte {
+	lm := &l.limiter
+	if lm.sLines == 0 {
+		return nil
+	}
+	lines, bytes := lm.sLines, lm.sBytes
+	lm.sLines, lm.sBytes = 0, 0
+	if l.JSONLines {
+		return []byte(fmt.Sprintf("{\"msg\":\"rate limit suppressed %d lines, %d bytes\","+
+			"\"suppressed_lines\":%d,\"suppressed_bytes\":%d}\n", lines, bytes, lines, bytes))
+	}
+	return []byte(fmt.Sprintf("rate limit suppressed %d lines, %d bytes\n", lines, bytes))
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
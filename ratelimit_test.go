@@ -0,0 +1,124 @@
+package logroller
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRateLimit", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename: logFile(tmp),
+		RateLimit: &RateLimit{
+			LinesPerSecond: 1,
+			BurstLines:     2,
+			SampleEvery:    5,
+		},
+	}
+	defer l.Close()
+
+	write := func(s string) {
+		_, err := l.Write([]byte(s))
+		isNilUp(err, t, 1)
+	}
+	// the burst of 2 goes, then every 5th line over the limit.
+	write(strings.Repeat("x\n", 12))
+	existsWithContent(l.Filename, []byte("x\nx\nx\nx\n"), t)
+
+	// a second later there is room for one more, after the summary.
+	fakeCurrentTime = fakeCurrentTime.Add(time.Second)
+	write("y\ny\n")
+	existsWithContent(l.Filename, []byte("x\nx\nx\nx\n"+
+		"rate limit suppressed 8 lines, 16 bytes\ny\n"), t)
+
+	// what is left unreported is reported on Close.
+	isNil(l.Close(), t)
+	existsWithContent(l.Filename, []byte("x\nx\nx\nx\n"+
+		"rate limit suppressed 8 lines, 16 bytes\ny\n"+
+		"rate limit suppressed 1 lines, 2 bytes\n"), t)
+}
+
+func TestRateLimitBytes(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRateLimitBytes", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:  logFile(tmp),
+		RateLimit: &RateLimit{BytesPerSecond: 10},
+	}
+	defer l.Close()
+
+	// the first line is bigger than the bucket, so it leaves it in
+	// debt for the next.
+	_, err := l.Write([]byte("0123456789abcdef\nz\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("0123456789abcdef\n"), t)
+	fakeCurrentTime = fakeCurrentTime.Add(time.Second)
+	_, err = l.Write([]byte("a\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("0123456789abcdef\n"+
+		"rate limit suppressed 1 lines, 2 bytes\na\n"), t)
+}
+
+func TestRateLimitRotation(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRateLimitRotation", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:     logFile(tmp),
+		MaxSizeBytes: 20,
+		RateLimit:    &RateLimit{LinesPerSecond: 1},
+	}
+	defer l.Close()
+
+	// the summary goes in the file that was current while the lines
+	// were suppressed, not with the line that starts the next one.
+	for _, s := range []string{"0123456789abcdef\n", "b\n"} {
+		_, err := l.Write([]byte(s))
+		isNil(err, t)
+	}
+	fakeCurrentTime = fakeCurrentTime.Add(time.Second)
+	_, err := l.Write([]byte("0123456789abcdef\n"))
+	isNil(err, t)
+	existsWithContent(backupFile(l.archiveDir()), []byte("0123456789abcdef\n"+
+		"rate limit suppressed 1 lines, 2 bytes\n"), t)
+	existsWithContent(l.Filename, []byte("0123456789abcdef\n"), t)
+}
+
+func TestRateLimitBelowOne(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRateLimitBelowOne", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:  logFile(tmp),
+		RateLimit: &RateLimit{LinesPerSecond: 0.1, BytesPerSecond: 0.1},
+	}
+	defer l.Close()
+
+	// a line every ten seconds fits the line rate, but each line
+	// of two bytes needs twenty seconds of the byte rate.
+	_, err := l.Write([]byte("a\nb\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("a\n"), t)
+	fakeCurrentTime = fakeCurrentTime.Add(10 * time.Second)
+	_, err = l.Write([]byte("c\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("a\n"), t)
+	fakeCurrentTime = fakeCurrentTime.Add(15 * time.Second)
+	_, err = l.Write([]byte("d\n"))
+	isNil(err, t)
+	existsWithContent(l.Filename, []byte("a\n"+
+		"rate limit suppressed 2 lines, 4 bytes\nd\n"), t)
+}
@@ -0,0 +1,277 @@
+package logroller
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ensure we always implement io.ReadCloser
+var _ io.ReadCloser = (*Reader)(nil)
+
+// Reader streams the logs written by a Logger configuration as
+// one continuous io.Reader: the rotated archives, oldest first,
+// transparently decompressed, followed by the current log file.
+//
+// Reader is meant for offline analysis. If the Logger rotates
+// while a Reader is part way through, the Reader may miss or
+// repeat the lines of the file that was rotated.
+type Reader struct {
+	// StripPreamble drops the replayed Preamble and Header, up to
+	// and including the end of preamble marker, from the top of
+	// each file. Set it before the first Read.
+	StripPreamble bool
+
+	cfg      *Logger
+	from, to time.Time
+
+	listed bool
+	files  []string
+	cur    io.Reader
+	closer []io.Closer
+	err    error
+}
+
+// NewReader returns a Reader over the log files of cfg whose
+// contents may fall within [from, to]. A zero from or to leaves
+// that end of the range open. Since a file holds everything
+// written between the previous rotation and its own, a file is
+// selected using the timestamps of both rotations; the lines
+// inside the selected files are not filtered.
+//
+// Files are located lazily, so any error is returned by Read.
+func NewReader(cfg *Logger, from, to time.Time) *Reader {
+	return &Reader{cfg: cfg, from: from, to: to}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if !r.listed {
+		r.listed = true
+		r.files, r.err = r.cfg.logFilesBetween(r.from, r.to)
+		if r.err != nil {
+			return 0, r.err
+		}
+	}
+	for {
+		if r.cur == nil {
+			if len(r.files) == 0 {
+				r.err = io.EOF
+				return 0, io.EOF
+			}
+			name := r.files[0]
+			r.files = r.files[1:]
+			if err := r.open(name); err != nil {
+				if os.IsNotExist(err) {
+					// deleted from under us.
+					continue
+				}
+				r.err = err
+				return 0, err
+			}
+		}
+		n, err = r.cur.Read(p)
+		if err == io.EOF {
+			r.closeCurrent()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close implements io.Closer.
+func (r *Reader) Close() error {
+	err := r.closeCurrent()
+	r.files = nil
+	r.listed = true
+	if r.err == nil {
+		r.err = fmt.Errorf("logroller: read from closed Reader")
+	}
+	return err
+}
+
+// open makes name the current file.
+func (r *Reader) open(name string) error {
+	rc, closers, _, err := r.cfg.openLogFileAnyForm(name)
+	if err != nil {
+		return err
+	}
+	r.closer = closers
+	r.cur = rc
+	if r.StripPreamble {
+		r.cur, err = stripPreamble(rc, r.cfg.endOfPreambleMarker())
+		if err != nil {
+			r.closeCurrent()
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) closeCurrent() error {
+	var err error
+	for i := len(r.closer) - 1; i >= 0; i-- {
+		if e := r.closer[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	r.closer = nil
+	r.cur = nil
+	return err
+}
+
+// maxHeaderPeek bounds how far into a file we look for the end of
+// preamble marker.
+const maxHeaderPeek = 1024 * 1024
+
+// stripPreamble returns a reader over src with everything up to and
+// including the end of preamble marker line dropped, if that marker
+// appears near the top of src.
+func stripPreamble(src io.Reader, marker string) (io.Reader, error) {
+	br := bufio.NewReaderSize(src, maxHeaderPeek)
+	head, err := br.Peek(maxHeaderPeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	rest := skipHeader(head, []byte(marker))
+	br.Discard(len(head) - len(rest))
+	return br, nil
+}
+
+// openLogFile opens the named log file for reading, decrypting and
+// decompressing it if it is an encrypted or compressed archive. The
+// closers must be closed in reverse order when done.
+func (l *Logger) openLogFile(name string) (io.Reader, []io.Closer, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var r io.Reader = f
+	closers := []io.Closer{f}
+	plain := name
+	if strings.HasSuffix(plain, encryptFileExtension) {
+		d, err := newDecryptReader(f, l.Encryption)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("can't decrypt %s: %s", name, err)
+		}
+		r = d
+		plain = strings.TrimSuffix(plain, encryptFileExtension)
+	}
+	if !strings.HasSuffix(plain, compressFileExtension) {
+		return r, closers, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("can't decompress %s: %s", name, err)
+	}
+	return gz, append(closers, gz), nil
+}
+
+// openLogFileAnyForm opens name as openLogFile does, unless it is an
+// archive that has been compressed or encrypted since it was listed,
+// in which case it opens the form that replaced it. It returns the
+// name of the file it opened.
+func (l *Logger) openLogFileAnyForm(name string) (io.Reader, []io.Closer, string, error) {
+	r, closers, err := l.openLogFile(name)
+	if !os.IsNotExist(err) || name == l.filename() {
+		return r, closers, name, err
+	}
+	plain := stripArchiveExt(name)
+	for _, suffix := range archiveSuffixes {
+		if plain+suffix == name {
+			continue
+		}
+		r, closers, err := l.openLogFile(plain + suffix)
+		if !os.IsNotExist(err) {
+			return r, closers, plain + suffix, err
+		}
+	}
+	return nil, nil, name, err
+}
+
+// archivedLogFiles returns the rotated log files in the archive
+// directory, compressed, encrypted or not, newest first. If a rotated
+// file is present in more than one form, because compression or
+// encryption is under way, only the least processed one is returned.
+func (l *Logger) archivedLogFiles() ([]logInfo, error) {
+	if _, err := os.Stat(l.archiveDir()); os.IsNotExist(err) {
+		return nil, nil
+	}
+	all, err := l.allArchives()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[time.Time]bool, len(all))
+	var files []logInfo
+	for _, f := range all {
+		if !seen[f.timestamp] {
+			seen[f.timestamp] = true
+			files = append(files, f)
+		}
+	}
+	sort.Sort(byFormatTime(files))
+	return files, nil
+}
+
+// allArchives returns the rotated log files in every form, least
+// processed form first.
+func (l *Logger) allArchives() ([]logInfo, error) {
+	var all []logInfo
+	for _, suffix := range archiveSuffixes {
+		files, err := l.archivesWithSuffix(suffix)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, files...)
+	}
+	return all, nil
+}
+
+// logFilesBetween returns the full paths of the archived and current
+// log files that may hold writes made within [from, to], oldest first.
+func (l *Logger) logFilesBetween(from, to time.Time) ([]string, error) {
+	archives, err := l.archivedLogFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	var start time.Time // the start of the oldest file is unknown
+	for i := len(archives) - 1; i >= 0; i-- {
+		end := archives[i].timestamp
+		if overlaps(start, end, from, to) {
+			names = append(names, filepath.Join(l.archiveDir(), archives[i].Name()))
+		}
+		start = end
+	}
+	if overlaps(start, time.Time{}, from, to) {
+		names = append(names, l.filename())
+	}
+	return names, nil
+}
+
+// overlaps reports whether the span [start, end] intersects
+// [from, to], where a zero time leaves that end unbounded.
+func overlaps(start, end, from, to time.Time) bool {
+	if !from.IsZero() && !end.IsZero() && end.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !start.IsZero() && start.After(to) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestReader", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:          logFile(tmp),
+		MaxSizeBytes:      100,
+		PreambleLineCount: 1,
+	}
+	defer l.Close()
+
+	var stamps []time.Time
+	for _, line := range []string{"version 1\n", "a\n", "b\n", "c\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		stamps = append(stamps, fakeTime())
+		isNil(l.Rotate(), t)
+	}
+	_, err := l.Write([]byte("d\n"))
+	isNil(err, t)
+
+	// compress the two oldest archives.
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	for _, f := range files[2:] {
+		isNil(compressLog(filepath.Join(l.archiveDir(), f.Name())), t)
+	}
+
+	r := NewReader(l, time.Time{}, time.Time{})
+	got, err := ioutil.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("version 1\n"+
+		"version 1\n"+endOfPreamble+"a\n"+
+		"version 1\n"+endOfPreamble+"b\n"+
+		"version 1\n"+endOfPreamble+"c\n"+
+		"version 1\n"+endOfPreamble+"d\n", string(got), t)
+
+	r = NewReader(l, stamps[1].Add(time.Hour), stamps[2].Add(time.Hour))
+	r.StripPreamble = true
+	got, err = ioutil.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("b\nc\n", string(got), t)
+
+	r = NewReader(l, stamps[3].Add(time.Hour), time.Time{})
+	r.StripPreamble = true
+	got, err = ioutil.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("d\n", string(got), t)
+}
+
+func TestReaderArchiveCompressedWhileListed(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestReaderArchiveCompressedWhileListed", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:     logFile(tmp),
+		MaxSizeBytes: 100,
+	}
+	defer l.Close()
+
+	for _, line := range []string{"a\n", "b\n"} {
+		_, err := l.Write([]byte(line))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+	_, err := l.Write([]byte("c\n"))
+	isNil(err, t)
+
+	// list the files and start on the oldest, then compress the
+	// next one before the Reader gets to it.
+	r := NewReader(l, time.Time{}, time.Time{})
+	buf := make([]byte, 1)
+	n, err := r.Read(buf)
+	isNil(err, t)
+	equals(1, n, t)
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	isNil(compressLog(filepath.Join(l.archiveDir(), files[0].Name())), t)
+
+	rest, err := ioutil.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("a\nb\nc\n", string(buf)+string(rest), t)
+}
@@ -0,0 +1,188 @@
+package logroller
+
+import (
+	"regexp"
+)
+
+// redactedText replaces each secret found by the built-in Redactors.
+const redactedText = "[REDACTED]"
+
+// Redactor scrubs secrets from a line before it is written.
+type Redactor interface {
+	// Name labels the Redactor's count in RedactionStats.
+	Name() string
+
+	// Redact returns line with any secrets replaced, and how many
+	// it replaced. It may modify line in place.
+	Redact(line []byte) ([]byte, int)
+}
+
+// RegexRedactor is a Redactor that replaces the matches of a regular
+// expression.
+type RegexRedactor struct {
+	// Label is returned by Name.
+	Label string
+
+	// Re finds candidate secrets.
+	Re *regexp.Regexp
+
+	// Replacement replaces each match, after $1 and ${name}
+	// references to Re's submatches are expanded, as with
+	// regexp.Regexp.Expand.
+	Replacement string
+
+	// Valid, if set, must accept a match for it to be replaced,
+	// for checks a regular expression can't express.
+	Valid func(match []byte) bool
+}
+
+// NewRegexRedactor returns a RegexRedactor named name that replaces
+// the matches of pattern with replacement.
+func NewRegexRedactor(name, pattern, replacement string) (*RegexRedactor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexRedactor{Label: name, Re: re, Replacement: replacement}, nil
+}
+
+// Name implements Redactor.
+func (r *RegexRedactor) Name() string {
+	return r.Label
+}
+
+// Redact implements Redactor.
+func (r *RegexRedactor) Redact(line []byte) ([]byte, int) {
+	matches := r.Re.FindAllSubmatchIndex(line, -1)
+	if matches == nil {
+		return line, 0
+	}
+	var out []byte
+	n, last := 0, 0
+	for _, m := range matches {
+		if r.Valid != nil && !r.Valid(line[m[0]:m[1]]) {
+			continue
+		}
+		out = append(out, line[last:m[0]]...)
+		out = r.Re.Expand(out, []byte(r.Replacement), line, m)
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return line, 0
+	}
+	return append(out, line[last:]...), n
+}
+
+// BearerTokenRedactor redacts the token after "Bearer", as in an
+// HTTP Authorization header.
+func BearerTokenRedactor() *RegexRedactor {
+	return &RegexRedactor{
+		Label:       "bearer",
+		Re:          regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+		Replacement: "${1}" + redactedText,
+	}
+}
+
+// AWSKeyRedactor redacts AWS access key IDs, and secret access keys
+// given as aws_secret_access_key=...
+func AWSKeyRedactor() *RegexRedactor {
+	return &RegexRedactor{
+		Label: "aws",
+		Re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b|` +
+			`((?i:aws_secret_access_key)["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`),
+		Replacement: "${1}" + redactedText,
+	}
+}
+
+// EmailRedactor redacts email addresses.
+func EmailRedactor() *RegexRedactor {
+	return &RegexRedactor{
+		Label:       "email",
+		Re:          regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: redactedText,
+	}
+}
+
+// CreditCardRedactor redacts runs of 13 to 19 digits, optionally
+// separated by spaces or dashes, that pass the Luhn check.
+func CreditCardRedactor() *RegexRedactor {
+	return &RegexRedactor{
+		Label:       "creditcard",
+		Re:          regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Replacement: redactedText,
+		Valid:       luhn,
+	}
+}
+
+// BuiltinRedactors returns all of the built-in Redactors.
+func BuiltinRedactors() []Redactor {
+	return []Redactor{
+		BearerTokenRedactor(),
+		AWSKeyRedactor(),
+		EmailRedactor(),
+		CreditCardRedactor(),
+	}
+}
+
+// luhn reports whether the digits in s pass the Luhn check.
+func luhn(s []byte) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
+
+// redacting reports whether any Redactors are set.
+func (l *Logger) redacting() bool {
+	return len(l.Redactors) > 0
+}
+
+// redact passes line through each of the Redactors in turn,
+// counting what they replace.
+func (l *Logger) redact(line []byte) []byte {
+	for _, r := range l.Redactors {
+		var n int
+		line, n = r.Redact(line)
+		if n > 0 {
+			if l.redactions == nil {
+				l.redactions = make(map[string]int64)
+			}
+			l.redactions[r.Name()] += int64(n)
+		}
+	}
+	return line
+}
+
+// redactStrings redacts each of lines.
+func (l *Logger) redactStrings(lines []string) []string {
+	for i, line := range lines {
+		lines[i] = string(l.redact([]byte(line)))
+	}
+	return lines
+}
+
+// RedactionStats returns how many secrets each of the Redactors has
+// replaced, by name, since the Logger was created.
+func (l *Logger) RedactionStats() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make(map[string]int64, len(l.redactions))
+	for name, n := range l.redactions {
+		stats[name] = n
+	}
+	return stats
+}
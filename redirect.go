@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package logroller
+
+import (
+	"errors"
+	"os"
+)
+
+// RedirectStdio is only supported on Linux; elsewhere it returns an
+// error.
+func (l *Logger) RedirectStdio() error {
+	return errors.New("RedirectStdio is only supported on Linux")
+}
+
+func dupStdio(_ *os.File) error {
+	return nil
+}
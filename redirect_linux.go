@@ -0,0 +1,39 @@
+package logroller
+
+import (
+	"os"
+	"syscall"
+)
+
+// RedirectStdio points the process's stdout and stderr file
+// descriptors at the current log file, opening it if need be, and
+// again at each new file after a rotation, so that output which
+// bypasses the Logger, such as panic traces, goroutine dumps and
+// runtime crash reports, lands in the log. Such output is not
+// counted toward MaxSizeBytes, nor seen by the Preamble or Footer,
+// though checksums, the manifest and the HashChain cover it, as
+// they are then taken from the rotated file itself. After Close, stdout and stderr keep writing to the
+// last file until the next write reopens one.
+func (l *Logger) RedirectStdio() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		if err := l.openExistingOrNew(0); err != nil {
+			return err
+		}
+	}
+	l.redirectStdio = true
+	l.hash = nil
+	return dupStdio(l.file)
+}
+
+// dupStdio makes stdout and stderr refer to f.
+func dupStdio(f *os.File) error {
+	for _, std := range []*os.File{os.Stdout, os.Stderr} {
+		// Dup3 rather than Dup2, which some architectures lack.
+		if err := syscall.Dup3(int(f.Fd()), int(std.Fd()), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
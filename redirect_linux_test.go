@@ -0,0 +1,91 @@
+package logroller
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRedirectStdio(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRedirectStdio", t)
+	defer os.RemoveAll(tmp)
+
+	// put the test's own stdout and stderr back afterward.
+	for _, std := range []*os.File{os.Stdout, os.Stderr} {
+		saved, err := syscall.Dup(int(std.Fd()))
+		isNil(err, t)
+		defer func(fd, saved int) {
+			syscall.Dup3(saved, fd, 0)
+			syscall.Close(saved)
+		}(int(std.Fd()), saved)
+	}
+
+	l := &Logger{Filename: logFile(tmp)}
+	defer l.Close()
+	isNil(l.RedirectStdio(), t)
+
+	fmt.Fprint(os.Stderr, "panic: one\n")
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	fmt.Fprint(os.Stdout, "goroutine 1\n")
+	fmt.Fprint(os.Stderr, "panic: two\n")
+
+	data, err := ioutil.ReadFile(backupFile(l.archiveDir()))
+	isNil(err, t)
+	equals("panic: one\n", string(data), t)
+	data, err = ioutil.ReadFile(l.Filename)
+	isNil(err, t)
+	equals("goroutine 1\npanic: two\n", string(data), t)
+}
+
+func TestRedirectStdioChecksums(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRedirectStdioChecksums", t)
+	defer os.RemoveAll(tmp)
+
+	for _, std := range []*os.File{os.Stdout, os.Stderr} {
+		saved, err := syscall.Dup(int(std.Fd()))
+		isNil(err, t)
+		defer func(fd, saved int) {
+			syscall.Dup3(saved, fd, 0)
+			syscall.Close(saved)
+		}(int(std.Fd()), saved)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	isNil(err, t)
+	l := &Logger{
+		Filename:     logFile(tmp),
+		Checksums:    true,
+		KeepManifest: true,
+		HashChain:    true,
+		SigningKey:   priv,
+	}
+	defer l.Close()
+	_, err = l.Write([]byte("before\n"))
+	isNil(err, t)
+	isNil(l.RedirectStdio(), t)
+
+	// what bypasses the Logger is in the recorded checksums too.
+	for i := 0; i < 2; i++ {
+		fmt.Fprint(os.Stderr, "panic: boo\n")
+		_, err = l.Write([]byte("after\n"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+
+	r, err := l.Verify()
+	isNil(err, t)
+	equals(2, len(r.OK), t)
+	assert(r.Intact(), t, "modified %v, missing %v", r.Modified, r.Missing)
+	c, err := l.VerifyChain(pub)
+	isNil(err, t)
+	assert(c.Intact(), t, "expected intact chain, got %+v", c)
+}
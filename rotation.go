@@ -0,0 +1,60 @@
+package logroller
+
+import (
+	"time"
+)
+
+// RotationReason records why a new log file was started.
+type RotationReason string
+
+const (
+	// RotateStartup means no current log file existed (or it
+	// could not be reopened), so a fresh one was created.
+	RotateStartup RotationReason = "startup"
+
+	// RotateSize means the current file was full.
+	RotateSize RotationReason = "size"
+
+	// RotateManual means Rotate was called.
+	RotateManual RotationReason = "manual"
+
+	// RotateSignal means the rotation was asked for by a signal,
+	// such as SIGHUP, via RotateWithReason.
+	RotateSignal RotationReason = "signal"
+)
+
+// RotationInfo describes the opening of a new log file, and is
+// handed to the Header hook.
+type RotationInfo struct {
+	// Time is the time of the rotation, from the (mockable)
+	// current time.
+	Time time.Time
+
+	// Reason is why the new file was started.
+	Reason RotationReason
+
+	// Filename is the name of the new, current log file.
+	Filename string
+
+	// PreviousFile is the name the previous log file was moved
+	// to, or empty if there was no previous file.
+	PreviousFile string
+
+	// PreviousSHA256 is the hex SHA-256 of the previous file's
+	// contents, when the Logger is hashing its files (for
+	// KeepManifest, Checksums or HashChain), or empty.
+	PreviousSHA256 string
+}
+
+// newRotationInfo describes a rotation happening now, including
+// the backup name that the current file will be moved to.
+func (l *Logger) newRotationInfo(reason RotationReason) RotationInfo {
+	t := currentTime()
+	name := l.filename()
+	return RotationInfo{
+		Time:         t,
+		Reason:       reason,
+		Filename:     name,
+		PreviousFile: backupName(name, l.archiveDir(), t, l.LocalTime),
+	}
+}
@@ -0,0 +1,202 @@
+package logroller
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a line, as parsed by a Router. Its values
+// match those of log/slog's levels.
+type Level int
+
+const (
+	LevelTrace Level = -8
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+	LevelFatal Level = 12
+)
+
+// defaultLevelPattern finds a slog-style level=X or "level":"X"
+// field, or else a bare upper case level name.
+var defaultLevelPattern = regexp.MustCompile(
+	`(?i:\blevel)["']?\s*[=:]\s*["']?([A-Za-z]+(?:[+-]\d+)?)|` +
+		`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|ERR|FATAL|CRITICAL|PANIC)\b`)
+
+// Route sends the lines of at least MinLevel to Logger.
+type Route struct {
+	MinLevel Level
+	Logger   *Logger
+}
+
+// Router is an io.Writer that parses the level of each line written
+// to it and writes the line to the Logger of every Route whose
+// MinLevel it reaches. Each Logger keeps its own rotation and
+// retention settings. For example, to keep errors in app.error.log
+// as well as everything in app.log:
+//
+//	r := &logroller.Router{Routes: []logroller.Route{
+//		{MinLevel: logroller.LevelTrace, Logger: &logroller.Logger{Filename: "app.log"}},
+//		{MinLevel: logroller.LevelError, Logger: &logroller.Logger{Filename: "app.error.log", MaxBackups: 30}},
+//	}}
+//	log.SetOutput(r)
+//
+// An incomplete trailing line is held until its newline arrives, or
+// Close, or until it grows past MaxPendingBytes.
+type Router struct {
+	Routes []Route
+
+	// LevelPattern finds the level in a line: its first non-empty
+	// submatch, or else the whole match, is parsed as a level name,
+	// such as INFO or WARNING, or as a slog level, such as
+	// ERROR+2. By default a slog level=X or "level":"X" field is
+	// looked for, and then a bare level name in upper case.
+	LevelPattern *regexp.Regexp
+
+	// DefaultLevel is the level of a line with no level that
+	// LevelPattern finds. It defaults to LevelInfo.
+	DefaultLevel Level
+
+	// MaxPendingBytes bounds how much of an incomplete line the
+	// Router will hold before routing it anyway. It defaults to 64
+	// kilobytes.
+	MaxPendingBytes int
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// Write implements io.Writer. It reports the first error from the
+// Loggers, but writes to all of them regardless, and so always
+// reports all of p as written: p should not be written again after
+// an error, as that would repeat its lines on the other routes.
+func (r *Router) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, p...)
+	if i := bytes.LastIndexByte(r.pending, '\n'); i >= 0 {
+		b := r.pending[:i+1]
+		for len(b) > 0 {
+			line := b[:bytes.IndexByte(b, '\n')+1]
+			b = b[len(line):]
+			if werr := r.route(line); err == nil {
+				err = werr
+			}
+		}
+		rest := copy(r.pending, r.pending[i+1:])
+		r.pending = r.pending[:rest]
+	}
+	if len(r.pending) > r.maxPending() {
+		if werr := r.route(r.pending); err == nil {
+			err = werr
+		}
+		r.pending = r.pending[:0]
+	}
+	return len(p), err
+}
+
+// maxPending returns the most bytes of an incomplete line we will
+// hold.
+func (r *Router) maxPending() int {
+	if r.MaxPendingBytes == 0 {
+		return defaultMaxPendingBytes
+	}
+	return r.MaxPendingBytes
+}
+
+// Close writes out any incomplete line and closes every Logger.
+func (r *Router) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	if len(r.pending) > 0 {
+		err = r.route(r.pending)
+		r.pending = r.pending[:0]
+	}
+	closed := make(map[*Logger]bool)
+	for _, rt := range r.Routes {
+		if closed[rt.Logger] {
+			continue
+		}
+		closed[rt.Logger] = true
+		if cerr := rt.Logger.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// route writes line to each Route it qualifies for.
+func (r *Router) route(line []byte) error {
+	level := r.level(line)
+	var err error
+	for _, rt := range r.Routes {
+		if level < rt.MinLevel {
+			continue
+		}
+		if _, werr := rt.Logger.Write(line); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// level returns the level of line.
+func (r *Router) level(line []byte) Level {
+	re := r.LevelPattern
+	if re == nil {
+		re = defaultLevelPattern
+	}
+	m := re.FindSubmatch(line)
+	if m == nil {
+		return r.DefaultLevel
+	}
+	token := m[0]
+	for _, sub := range m[1:] {
+		if len(sub) > 0 {
+			token = sub
+			break
+		}
+	}
+	if level, ok := ParseLevel(string(token)); ok {
+		return level
+	}
+	return r.DefaultLevel
+}
+
+// ParseLevel parses a level name, such as "info" or "WARNING", with
+// an optional offset, as in slog's "ERROR+2".
+func ParseLevel(s string) (Level, bool) {
+	var offset int
+	if i := strings.IndexAny(s, "+-"); i > 0 {
+		n, err := strconv.Atoi(s[i:])
+		if err != nil {
+			return 0, false
+		}
+		s, offset = s[:i], n
+	}
+	var level Level
+	switch strings.ToUpper(s) {
+	case "TRACE":
+		level = LevelTrace
+	case "DEBUG":
+		level = LevelDebug
+	case "INFO":
+		level = LevelInfo
+	case "WARN", "WARNING":
+		level = LevelWarn
+	case "ERROR", "ERR":
+		level = LevelError
+	case "FATAL", "CRITICAL", "PANIC":
+		level = LevelFatal
+	default:
+		return 0, false
+	}
+	return level + Level(offset), true
+}
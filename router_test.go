@@ -0,0 +1,110 @@
+package logroller
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRouter", t)
+	defer os.RemoveAll(tmp)
+
+	all := &Logger{Filename: filepath.Join(tmp, "app.log")}
+	errs := &Logger{Filename: filepath.Join(tmp, "app.error.log"), ArchiveDir: filepath.Join(tmp, "errors")}
+	r := &Router{Routes: []Route{
+		{MinLevel: LevelTrace, Logger: all},
+		{MinLevel: LevelError, Logger: errs},
+	}}
+	defer r.Close()
+
+	input := "time=x level=INFO msg=up\n" +
+		"{\"level\":\"ERROR\",\"msg\":\"down\"}\n" +
+		"2016/11/04 WARN disk\n" +
+		"no level at all\n" +
+		"level=WARN+4 msg=\"as bad as an error\"\n" +
+		"FATAL the e"
+	n, err := r.Write([]byte(input))
+	isNil(err, t)
+	equals(len(input), n, t)
+	_, err = r.Write([]byte("nd\n2016/11/04 DEBUG partial"))
+	isNil(err, t)
+	isNil(r.Close(), t)
+
+	existsWithContent(all.Filename, []byte(input+"nd\n2016/11/04 DEBUG partial"), t)
+	existsWithContent(errs.Filename, []byte("{\"level\":\"ERROR\",\"msg\":\"down\"}\n"+
+		"level=WARN+4 msg=\"as bad as an error\"\n"+
+		"FATAL the end\n"), t)
+}
+
+func TestRouterPattern(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRouterPattern", t)
+	defer os.RemoveAll(tmp)
+
+	warn := &Logger{Filename: filepath.Join(tmp, "warn.log")}
+	r := &Router{
+		Routes:       []Route{{MinLevel: LevelWarn, Logger: warn}},
+		LevelPattern: regexp.MustCompile(`^\[([a-z]+)\]`),
+		DefaultLevel: LevelError,
+	}
+	defer r.Close()
+
+	_, err := r.Write([]byte("[info] a\n[warning] b\n[bogus] c\nd\n"))
+	isNil(err, t)
+	existsWithContent(warn.Filename, []byte("[warning] b\n[bogus] c\nd\n"), t)
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, exp := range map[string]Level{
+		"info":     LevelInfo,
+		"WARNING":  LevelWarn,
+		"ERROR+2":  LevelError + 2,
+		"DEBUG-4":  LevelDebug - 4,
+		"critical": LevelFatal,
+	} {
+		level, ok := ParseLevel(s)
+		assert(ok, t, "expected %q to parse", s)
+		equals(exp, level, t)
+	}
+	_, ok := ParseLevel("loud")
+	assert(!ok, t, "expected loud not to parse")
+}
+
+func TestRouterPendingAndErrors(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestRouterPendingAndErrors", t)
+	defer os.RemoveAll(tmp)
+
+	all := &Logger{Filename: filepath.Join(tmp, "app.log")}
+	small := &Logger{Filename: filepath.Join(tmp, "small.log"), MaxSizeBytes: 12}
+	r := &Router{
+		Routes: []Route{
+			{MinLevel: LevelTrace, Logger: all},
+			{MinLevel: LevelError, Logger: small},
+		},
+		MaxPendingBytes: 8,
+	}
+	defer r.Close()
+
+	// an incomplete line is routed once it outgrows MaxPendingBytes.
+	_, err := r.Write([]byte("ERROR no"))
+	isNil(err, t)
+	notExist(all.Filename, t)
+	_, err = r.Write([]byte("t"))
+	isNil(err, t)
+	existsWithContent(small.Filename, []byte("ERROR not"), t)
+
+	// a line one route refuses still reaches the others, and all of
+	// p is reported as written, so that it is not written twice.
+	p := "\nERROR far too long\n"
+	n, err := r.Write([]byte(p))
+	notNil(err, t)
+	equals(len(p), n, t)
+	existsWithContent(all.Filename, []byte("ERROR not\nERROR far too long\n"), t)
+}
@@ -0,0 +1,96 @@
+package logroller
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Match is one line found by Search.
+type Match struct {
+	// File is the full path of the log file or archive that holds
+	// the line.
+	File string
+
+	// Line is the 1-based line number within File, counting any
+	// replayed Preamble and Header lines.
+	Line int64
+
+	// Text is the line, without its trailing newline.
+	Text string
+}
+
+// Search returns the lines matching the regular expression pattern
+// in those log files, archived or current, that may hold writes made
+// within [from, to]. See SearchFunc.
+func (l *Logger) Search(pattern string, from, to time.Time) ([]Match, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	err = l.SearchFunc(re, from, to, func(m Match) error {
+		matches = append(matches, m)
+		return nil
+	})
+	return matches, err
+}
+
+// SearchFunc calls fn for each line matching re, oldest file first.
+// As with NewReader, only the files whose rotation timestamps show
+// they may overlap [from, to] are opened, and compressed archives are
+// decompressed on the fly; a zero from or to leaves that end of the
+// range open. If fn returns an error the search stops and returns it.
+func (l *Logger) SearchFunc(re *regexp.Regexp, from, to time.Time, fn func(Match) error) error {
+	files, err := l.logFilesBetween(from, to)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		err := l.searchFile(name, re, fn)
+		if os.IsNotExist(err) {
+			// deleted from under us.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Logger) searchFile(name string, re *regexp.Regexp, fn func(Match) error) error {
+	r, closers, name, err := l.openLogFileAnyForm(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+
+	br := bufio.NewReader(r)
+	var lineno int64
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			lineno++
+			line = strings.TrimSuffix(line, "\n")
+			if re.MatchString(line) {
+				if err := fn(Match{File: name, Line: lineno, Text: line}); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package logroller
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestSearch", t)
+	defer os.RemoveAll(tmp)
+
+	filename := logFile(tmp)
+	l := &Logger{
+		Filename:     filename,
+		MaxSizeBytes: 100,
+	}
+	defer l.Close()
+	adir := l.archiveDir()
+
+	var rotated []time.Time
+	for _, lines := range []string{"ok\nerror one\n", "ok\nok\nerror two\n", "error three\n"} {
+		_, err := l.Write([]byte(lines))
+		isNil(err, t)
+		newFakeTime()
+		rotated = append(rotated, fakeTime())
+		isNil(l.Rotate(), t)
+	}
+	_, err := l.Write([]byte("ok\nerror four\n"))
+	isNil(err, t)
+
+	files, err := l.oldLogFiles(false)
+	isNil(err, t)
+	isNil(compressLog(filepath.Join(adir, files[len(files)-1].Name())), t)
+	first := filepath.Join(adir, files[len(files)-1].Name()) + compressFileExtension
+
+	matches, err := l.Search(`^error`, time.Time{}, time.Time{})
+	isNil(err, t)
+	equals([]Match{
+		{File: first, Line: 2, Text: "error one"},
+		{File: filepath.Join(adir, files[1].Name()), Line: 3, Text: "error two"},
+		{File: filepath.Join(adir, files[0].Name()), Line: 1, Text: "error three"},
+		{File: filename, Line: 2, Text: "error four"},
+	}, matches, t)
+
+	// only the second and third files can hold writes from between
+	// the first and second rotations.
+	matches, err = l.Search(`error`, rotated[0].Add(time.Hour), rotated[1].Add(time.Hour))
+	isNil(err, t)
+	equals(2, len(matches), t)
+	equals("error two", matches[0].Text, t)
+	equals("error three", matches[1].Text, t)
+
+	// an archive compressed after the files were listed is found in
+	// its new form.
+	second := filepath.Join(adir, files[1].Name())
+	var got []Match
+	err = l.SearchFunc(regexp.MustCompile(`^error`), time.Time{}, time.Time{}, func(m Match) error {
+		if len(got) == 0 {
+			isNil(compressLog(second), t)
+		}
+		got = append(got, m)
+		return nil
+	})
+	isNil(err, t)
+	equals(4, len(got), t)
+	equals(Match{File: second + compressFileExtension, Line: 3, Text: "error two"}, got[1], t)
+
+	_, err = l.Search(`(`, time.Time{}, time.Time{})
+	notNil(err, t)
+}
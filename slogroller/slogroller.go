@@ -0,0 +1,151 @@
+//go:build go1.21
+// +build go1.21
+
+// Package slogroller provides a log/slog Handler that writes to a
+// logroller.Logger.
+//
+// Each record is formatted by slog's own JSON or text handler, which
+// escape any newlines within it, and handed to the Logger in a single
+// Write, so every record is exactly one line and rotation never
+// splits one.
+//
+//	l := &logroller.Logger{Filename: "/var/log/myapp/foo.log"}
+//	logger := slog.New(slogroller.New(l, &slogroller.Options{JSON: true}))
+//	logger.Info("starting", "version", version, slogroller.Preamble())
+//
+// Records carrying the Preamble attribute are also added to the
+// Logger's Preamble, so they are replayed at the top of every log
+// file.
+package slogroller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/glycerine/logroller"
+)
+
+// PreambleKey is the key of the attribute that Preamble returns.
+const PreambleKey = "logroller.preamble"
+
+// Preamble returns an attribute that marks a record for the Preamble.
+// The attribute itself is not written.
+func Preamble() slog.Attr {
+	return slog.Bool(PreambleKey, true)
+}
+
+// Options configure a Handler.
+type Options struct {
+	// HandlerOptions are passed on to slog's handler.
+	slog.HandlerOptions
+
+	// JSON selects slog's JSON format. The default is its text
+	// format.
+	JSON bool
+
+	// PreambleAttrs, if set, are logged by New as an Info record
+	// with the message "preamble", marked for the Preamble, to
+	// record such things as the version and configuration at the
+	// top of every log file.
+	PreambleAttrs []slog.Attr
+}
+
+// Handler is a slog.Handler that writes to a logroller.Logger.
+type Handler struct {
+	l    *logroller.Logger
+	opts Options
+	h    slog.Handler
+
+	// ops replays WithAttrs and WithGroup calls onto the handler
+	// that formats Preamble records.
+	ops []func(slog.Handler) slog.Handler
+}
+
+// New returns a Handler that writes to l. opts may be nil.
+func New(l *logroller.Logger, opts *Options) *Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	h := &Handler{l: l, opts: *opts}
+	h.h = h.format(l)
+	if len(opts.PreambleAttrs) > 0 {
+		attrs := append([]slog.Attr{Preamble()}, opts.PreambleAttrs...)
+		slog.New(h).LogAttrs(context.Background(), slog.LevelInfo, "preamble", attrs...)
+	}
+	return h
+}
+
+// format returns slog's handler for the configured format, writing
+// to w.
+func (h *Handler) format(w io.Writer) slog.Handler {
+	if h.opts.JSON {
+		return slog.NewJSONHandler(w, &h.opts.HandlerOptions)
+	}
+	return slog.NewTextHandler(w, &h.opts.HandlerOptions)
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler. A record marked with Preamble is
+// written, then appended to the Logger's Preamble.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	preamble := false
+	r.Attrs(func(a slog.Attr) bool {
+		if isPreamble(a) {
+			preamble = true
+			return false
+		}
+		return true
+	})
+	if !preamble {
+		return h.h.Handle(ctx, r)
+	}
+
+	clean := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if !isPreamble(a) {
+			clean.AddAttrs(a)
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	ph := h.format(&buf)
+	for _, op := range h.ops {
+		ph = op(ph)
+	}
+	if err := ph.Handle(ctx, clean); err != nil {
+		return err
+	}
+	// write before appending, so a file opened by this write
+	// doesn't replay the line as well.
+	if _, err := h.l.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return h.l.AppendPreamble(buf.String())
+}
+
+func isPreamble(a slog.Attr) bool {
+	return a.Key == PreambleKey && a.Value.Kind() == slog.KindBool && a.Value.Bool()
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(sh slog.Handler) slog.Handler { return sh.WithAttrs(attrs) })
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return h.with(func(sh slog.Handler) slog.Handler { return sh.WithGroup(name) })
+}
+
+func (h *Handler) with(op func(slog.Handler) slog.Handler) *Handler {
+	h2 := *h
+	h2.h = op(h.h)
+	h2.ops = append(h.ops[:len(h.ops):len(h.ops)], op)
+	return &h2
+}
@@ -0,0 +1,110 @@
+//go:build go1.21
+// +build go1.21
+
+package slogroller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glycerine/logroller"
+)
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestHandler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &logroller.Logger{Filename: filepath.Join(dir, "app.log"), MaxSizeBytes: 300}
+	defer l.Close()
+	logger := slog.New(New(l, &Options{
+		JSON:          true,
+		PreambleAttrs: []slog.Attr{slog.String("version", "1.2")},
+	}))
+
+	logger.With("svc", "api").Info("multi\nline", "n", 1)
+	for i := 0; i < 20; i++ {
+		logger.Info("filler", "i", i)
+	}
+
+	rotated, err := ioutil.ReadDir(dir + "/app.log.rotated")
+	if err != nil || len(rotated) == 0 {
+		t.Fatalf("expected rotated files, got %v, %v", rotated, err)
+	}
+	r := logroller.NewReader(l, time.Time{}, time.Time{})
+	r.StripPreamble = true
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 22 {
+		t.Fatalf("expected 22 records, got %d:\n%s", len(lines), data)
+	}
+	for _, line := range lines {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("record split or mangled: %q: %v", line, err)
+		}
+		if _, ok := rec[PreambleKey]; ok {
+			t.Errorf("preamble marker written in %q", line)
+		}
+	}
+	if !strings.Contains(lines[1], `"svc":"api"`) || !strings.Contains(lines[1], `"msg":"multi\nline"`) {
+		t.Errorf("unexpected record %q", lines[1])
+	}
+
+	if len(l.Preamble) != 1 || !strings.Contains(l.Preamble[0], `"version":"1.2"`) {
+		t.Fatalf("unexpected Preamble %q", l.Preamble)
+	}
+	current, err := ioutil.ReadFile(l.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(current, []byte(l.Preamble[0])) {
+		t.Errorf("preamble not replayed in %q", current)
+	}
+}
+
+func TestHandlerPreambleText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "TestHandlerPreambleText")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &logroller.Logger{Filename: filepath.Join(dir, "app.log")}
+	defer l.Close()
+	opts := &Options{}
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(New(l, opts)).WithGroup("g")
+	logger.Info("config", "port", 80, Preamble())
+	logger.Debug("hidden")
+
+	want := "level=INFO msg=config g.port=80\n"
+	data, err := ioutil.ReadFile(l.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	if len(l.Preamble) != 1 || l.Preamble[0] != want {
+		t.Errorf("got Preamble %q, want %q", l.Preamble, want)
+	}
+}
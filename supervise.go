@@ -0,0 +1,146 @@
+package logroller
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// forwardedSignals are the signals Supervise passes on to the child.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// Supervise starts cmd, writes its standard output to stdout and
+// its standard error to stderr line by line, and waits for it to
+// exit, forwarding SIGINT, SIGTERM, SIGHUP and SIGQUIT to it
+// meanwhile. stdout and stderr may be the same Logger.
+//
+// Once the child has started, its command line, pid and start time
+// are written to each Logger and made its Preamble with SetPreamble,
+// so every log file the run produces says where it came from.
+//
+// Supervise returns the child's exit code, or 128 plus the signal
+// number if a signal killed it, as a shell would. err is set only if
+// the child could not be run or its output could not be logged. The
+// Loggers are not closed.
+func Supervise(cmd *exec.Cmd, stdout, stderr *Logger) (exitCode int, err error) {
+	outPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return -1, err
+	}
+	errPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return -1, err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				// the child may have exited already.
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	lines := runPreamble(cmd, currentTime())
+	loggers := []*Logger{stdout}
+	if stderr != stdout {
+		loggers = append(loggers, stderr)
+	}
+	var logErr error
+	for _, l := range loggers {
+		if _, err := l.Write([]byte(lines)); err != nil && logErr == nil {
+			logErr = err
+		}
+		if err := l.SetPreamble(lines); err != nil && logErr == nil {
+			logErr = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	copyLines := func(l *Logger, r io.Reader) {
+		defer wg.Done()
+		if err := writeLines(l, r); err != nil {
+			mu.Lock()
+			if logErr == nil {
+				logErr = err
+			}
+			mu.Unlock()
+			// keep the child from blocking on a full pipe.
+			io.Copy(ioutil.Discard, r)
+		}
+	}
+	wg.Add(2)
+	go copyLines(stdout, outPipe)
+	go copyLines(stderr, errPipe)
+	// the pipes must be drained before Wait closes them.
+	wg.Wait()
+
+	err = cmd.Wait()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if ws, ok := exit.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal()), logErr
+		}
+		return exit.ExitCode(), logErr
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, logErr
+}
+
+// runPreamble returns the lines that record the start of cmd.
+func runPreamble(cmd *exec.Cmd, start time.Time) string {
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$") {
+			arg = strconv.Quote(arg)
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf("command: %s\nstarted: %s pid=%d\n",
+		strings.Join(args, " "), start.Format(time.RFC3339Nano), cmd.Process.Pid)
+}
+
+// writeLines copies r to l a line at a time, so that lines from
+// two streams sharing a Logger are never interleaved.
+func writeLines(l *Logger, r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := l.Write(line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
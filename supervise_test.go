@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package logroller
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestSupervise", t)
+	defer os.RemoveAll(tmp)
+
+	out := &Logger{Filename: filepath.Join(tmp, "out.log")}
+	defer out.Close()
+	errs := &Logger{Filename: filepath.Join(tmp, "err.log")}
+	defer errs.Close()
+
+	cmd := exec.Command("sh", "-c", "echo hello; echo oops >&2; printf partial; exit 3")
+	code, err := Supervise(cmd, out, errs)
+	isNil(err, t)
+	equals(3, code, t)
+
+	preamble := "command: sh -c \"echo hello; echo oops >&2; printf partial; exit 3\"\n" +
+		"started: " + fakeTime().Format(time.RFC3339Nano) +
+		" pid=" + strconv.Itoa(cmd.Process.Pid) + "\n"
+	data, err := ioutil.ReadFile(out.Filename)
+	isNil(err, t)
+	equals(preamble+"hello\npartial", string(data), t)
+	data, err = ioutil.ReadFile(errs.Filename)
+	isNil(err, t)
+	equals(preamble+"oops\n", string(data), t)
+	equals(strings.SplitAfter(preamble, "\n")[:2], out.Preamble, t)
+}
+
+func TestSuperviseSignal(t *testing.T) {
+	currentTime = fakeTime
+
+	tmp := makeTempDir("TestSuperviseSignal", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{Filename: filepath.Join(tmp, "both.log")}
+	defer l.Close()
+
+	code, err := Supervise(exec.Command("sh", "-c", "echo a; kill -TERM $$"), l, l)
+	isNil(err, t)
+	equals(128+15, code, t)
+
+	data, err := ioutil.ReadFile(l.Filename)
+	isNil(err, t)
+	assert(strings.HasSuffix(string(data), "\na\n"), t, "unexpected log %q", data)
+}
@@ -0,0 +1,312 @@
+package logroller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tailPollInterval is how often a tail checks for new data and
+// for rotation. It is a var so tests can shorten it.
+var tailPollInterval = 100 * time.Millisecond
+
+// Follow returns a channel that yields every line written to the
+// Logger's file from now on, without its trailing newline. When
+// the file is rotated, Follow finishes reading the old file, then
+// reads any files that were rotated away before it could look,
+// and only then moves on to the new current file, so no line is
+// missed or repeated. The replayed Preamble and Header at the top
+// of each new file are skipped. The channel is closed when ctx is
+// done.
+func (l *Logger) Follow(ctx context.Context) <-chan string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// holding the lock means no write is under way while we find
+	// the current end of the file.
+	cfg := &Logger{
+		Filename:            l.filename(),
+		ArchiveDir:          l.ArchiveDir,
+		EndOfPreambleMarker: l.EndOfPreambleMarker,
+		Encryption:          l.Encryption,
+	}
+	return tail(ctx, cfg)
+}
+
+// Tail is like Follow, but follows a log file by name, for example
+// one written by another process. It expects the default archive
+// directory and end of preamble marker.
+func Tail(ctx context.Context, filename string) <-chan string {
+	return tail(ctx, &Logger{Filename: filename})
+}
+
+func tail(ctx context.Context, cfg *Logger) <-chan string {
+	t := &tailer{
+		cfg:    cfg,
+		marker: []byte(cfg.endOfPreambleMarker()),
+		out:    make(chan string, 64),
+	}
+	// everything archived so far is history.
+	if archives, err := cfg.archivedLogFiles(); err == nil && len(archives) > 0 {
+		t.since = archives[0].timestamp
+	}
+	if f, err := os.Open(cfg.filename()); err == nil {
+		if _, err := f.Seek(0, io.SeekEnd); err == nil {
+			t.f = f
+		} else {
+			f.Close()
+		}
+	}
+	go t.run(ctx)
+	return t.out
+}
+
+// tailer follows one log file name across rotations.
+type tailer struct {
+	cfg    *Logger
+	marker []byte
+	out    chan string
+
+	f *os.File
+	// partial holds the start of a line whose newline we have
+	// not read yet.
+	partial []byte
+	// fresh is set when f is a newly opened file whose replayed
+	// header we have yet to look for.
+	fresh bool
+	// since is the timestamp of the newest archive we have
+	// accounted for.
+	since time.Time
+	// skipNext is set when the next archive after since is the
+	// file we just finished reading through its open handle.
+	skipNext bool
+}
+
+func (t *tailer) run(ctx context.Context) {
+	defer close(t.out)
+	defer func() {
+		if t.f != nil {
+			t.f.Close()
+		}
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		if t.f == nil {
+			if !t.catchUp(ctx) {
+				return
+			}
+			if f, err := os.Open(t.cfg.filename()); err == nil {
+				t.f = f
+				t.fresh = true
+				// a rotation may have slipped in before the open,
+				// in which case its archive comes first.
+				if t.newArchives() {
+					t.f.Close()
+					t.f = nil
+					continue
+				}
+			}
+		}
+		if t.f != nil {
+			if !t.drain(ctx, buf) {
+				return
+			}
+			switch {
+			case t.rotated():
+				// anything written before the rename is in the old
+				// file by now, since the Logger closes it first.
+				if !t.drain(ctx, buf) || !t.flushPartial(ctx) {
+					return
+				}
+				t.f.Close()
+				t.f = nil
+				t.skipNext = true
+				continue
+			case t.truncated():
+				t.f.Seek(0, io.SeekStart)
+				t.partial = t.partial[:0]
+				t.fresh = true
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
+
+// catchUp sends the lines of every archive newer than t.since,
+// oldest first, except the one we already read if skipNext is set.
+// It returns false if ctx is done.
+func (t *tailer) catchUp(ctx context.Context) bool {
+	for {
+		archives, err := t.cfg.archivedLogFiles()
+		if err != nil {
+			return ctx.Err() == nil
+		}
+		var todo []logInfo
+		for i := len(archives) - 1; i >= 0; i-- {
+			if archives[i].timestamp.After(t.since) {
+				todo = append(todo, archives[i])
+			}
+		}
+		if len(todo) == 0 {
+			return ctx.Err() == nil
+		}
+		for _, a := range todo {
+			if t.skipNext {
+				t.skipNext = false
+				t.since = a.timestamp
+				continue
+			}
+			ok, err := t.sendArchive(ctx, a)
+			if !ok {
+				return false
+			}
+			if os.IsNotExist(err) {
+				// compressed or deleted from under us; look again.
+				break
+			}
+			t.since = a.timestamp
+		}
+	}
+}
+
+// sendArchive sends every line of the archive a.
+func (t *tailer) sendArchive(ctx context.Context, a logInfo) (bool, error) {
+	r, closers, err := t.cfg.openLogFile(filepath.Join(t.cfg.archiveDir(), a.Name()))
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+	src, err := stripPreamble(r, string(t.marker))
+	if err != nil {
+		return true, err
+	}
+	br := bufio.NewReader(src)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if !t.send(ctx, strings.TrimSuffix(line, "\n")) {
+				return false, nil
+			}
+		}
+		if err != nil {
+			return true, nil
+		}
+	}
+}
+
+// newArchives reports whether anything has been archived since
+// t.since.
+func (t *tailer) newArchives() bool {
+	archives, err := t.cfg.archivedLogFiles()
+	return err == nil && len(archives) > 0 && archives[0].timestamp.After(t.since)
+}
+
+// drain reads t.f to EOF, sending each complete line. It returns
+// false if ctx is done.
+func (t *tailer) drain(ctx context.Context, buf []byte) bool {
+	for {
+		n, err := t.f.Read(buf)
+		if n > 0 {
+			data := buf[:n]
+			if t.fresh {
+				t.fresh = false
+				data = skipHeader(data, t.marker)
+			}
+			t.partial = append(t.partial, data...)
+			for {
+				i := bytes.IndexByte(t.partial, '\n')
+				if i < 0 {
+					break
+				}
+				if !t.send(ctx, string(t.partial[:i])) {
+					return false
+				}
+				t.partial = t.partial[i+1:]
+			}
+			// don't let the partial line pin a large array.
+			t.partial = append([]byte(nil), t.partial...)
+		}
+		if err != nil || n == 0 {
+			return ctx.Err() == nil
+		}
+	}
+}
+
+// flushPartial sends an unterminated last line.
+func (t *tailer) flushPartial(ctx context.Context) bool {
+	if len(t.partial) == 0 {
+		return true
+	}
+	line := string(t.partial)
+	t.partial = t.partial[:0]
+	return t.send(ctx, line)
+}
+
+func (t *tailer) send(ctx context.Context, line string) bool {
+	select {
+	case t.out <- line:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// rotated reports whether the file name no longer refers to the
+// file we have open.
+func (t *tailer) rotated() bool {
+	cur, err := os.Stat(t.cfg.filename())
+	if err != nil {
+		return true
+	}
+	open, err := t.f.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(cur, open)
+}
+
+// truncated reports whether the open file has shrunk below our
+// read offset, as when someone else truncates it.
+func (t *tailer) truncated() bool {
+	info, err := t.f.Stat()
+	if err != nil {
+		return false
+	}
+	off, err := t.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false
+	}
+	return info.Size() < off
+}
+
+// skipHeader drops everything up to and including the end of
+// preamble marker line, if data, the first bytes read from a new
+// file, contains one at the start of a line.
+func skipHeader(data, marker []byte) []byte {
+	i := 0
+	for {
+		if bytes.HasPrefix(data[i:], marker) {
+			return data[i+len(marker):]
+		}
+		j := bytes.IndexByte(data[i:], '\n')
+		if j < 0 {
+			return data
+		}
+		i += j + 1
+	}
+}
@@ -0,0 +1,65 @@
+package logroller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	currentTime = tickingFakeTime
+	defer func() { currentTime = fakeTime }()
+	tailPollInterval = time.Millisecond
+	defer func() { tailPollInterval = 100 * time.Millisecond }()
+
+	tmp := makeTempDir("TestFollow", t)
+	defer os.RemoveAll(tmp)
+
+	l := &Logger{
+		Filename:          logFile(tmp),
+		MaxSizeBytes:      60,
+		PreambleLineCount: 1,
+	}
+	defer l.Close()
+
+	_, err := l.Write([]byte("before follow\n"))
+	isNil(err, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := l.Follow(ctx)
+	tailed := Tail(ctx, logFile(tmp))
+
+	var exp []string
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("line %d", i)
+		exp = append(exp, line)
+		_, err := l.Write([]byte(line + "\n"))
+		isNil(err, t)
+		if i%7 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	for _, ch := range []<-chan string{lines, tailed} {
+		var got []string
+		for len(got) < len(exp) {
+			select {
+			case line := <-ch:
+				got = append(got, line)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out after %d lines: %v", len(got), got)
+			}
+		}
+		equals(exp, got, t)
+	}
+
+	// wait for both tails to finish before the deferred resets.
+	cancel()
+	for range lines {
+	}
+	for range tailed {
+	}
+}